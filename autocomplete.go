@@ -0,0 +1,287 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxChoices is the most suggestions discord will show.
+const maxChoices = 25
+
+// choiceLimit is the most characters discord allows in a suggestion's name
+// or value.
+const choiceLimit = 100
+
+// ownerOptions are the options which name an inventory.
+var ownerOptions = map[string]bool{
+	"owner":    true,
+	"buyer":    true,
+	"seller":   true,
+	"merchant": true,
+	"from":     true,
+	"to":       true,
+}
+
+// autocompleteHandler suggests values for the option a user is typing.
+// Members who can't view inventories get no suggestions.
+func (b backpack) autocompleteHandler(s *discordgo.Session, m *discordgo.InteractionCreate) {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	b, st, a, err := b.prepare(m)
+	if err == nil && a.canView() {
+		channel := fmt.Sprintf("<#%v>", m.ChannelID)
+		choices = b.suggest(
+			m.ApplicationCommandData(),
+			channel,
+			a.payer(channel),
+			st,
+			stateLabel(s, m.GuildID),
+		)
+	}
+	s.InteractionRespond(m.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
+
+// suggest returns choices for the focused option of a command. Items are
+// suggested from the inventory the subcommand would take them from: the
+// seller's items for sale when buying, the giver's items when giving, and so
+// on. Inventories which aren't given fall back to defaultOwner, or payer for
+// the inventory paying. label names owners for display.
+func (b backpack) suggest(
+	data discordgo.ApplicationCommandInteractionData,
+	defaultOwner string,
+	payer string,
+	st settings,
+	label func(string) string,
+) []*discordgo.ApplicationCommandOptionChoice {
+	if len(data.Options) != 1 {
+		return nil
+	}
+	subcommand := data.Options[0]
+	options := mapOptions(subcommand.Options)
+
+	var focused *discordgo.ApplicationCommandInteractionDataOption
+	for _, opt := range subcommand.Options {
+		if opt.Focused {
+			focused = opt
+		}
+	}
+	if focused == nil {
+		return nil
+	}
+	typed := focused.StringValue()
+	if ownerOptions[focused.Name] {
+		return b.ownerChoices(typed, st, label)
+	}
+	if focused.Name == "filter" {
+		return b.labelChoices(typed)
+	}
+	if focused.Name != "item" {
+		return nil
+	}
+
+	// The item's inventory falls back just like the command itself.
+	inventory := func(key, fallback string) string {
+		owner, err := getOwnerOrDefault(options, key, fallback, st)
+		if err != nil {
+			return fallback
+		}
+		return owner
+	}
+	switch subcommand.Name {
+	case "buy":
+		return b.itemChoices(typed, true, inventory("seller", defaultOwner))
+	case "sell":
+		return b.itemChoices(typed, false, inventory("seller", payer))
+	case "give":
+		return b.itemChoices(typed, false, inventory("from", payer))
+	case "history":
+		if _, ok := options["owner"]; ok {
+			return b.itemChoices(typed, false, inventory("owner", defaultOwner))
+		}
+		return b.itemChoices(typed, false)
+	case "describe", "item":
+		return b.itemChoices(typed, false)
+	default:
+		return b.itemChoices(typed, false, inventory("owner", defaultOwner))
+	}
+}
+
+// itemChoices suggests the items in owners' inventories which contain typed.
+// If no owners are given every inventory and described item is used.
+// forSale limits the suggestions to items with a price.
+func (b backpack) itemChoices(
+	typed string,
+	forSale bool,
+	owners ...string,
+) []*discordgo.ApplicationCommandOptionChoice {
+	storage := b.storage()
+	all := len(owners) == 0
+	if all {
+		var err error
+		owners, err = storage.owners()
+		if err != nil {
+			log.Printf("error suggesting items: %v\n", err)
+			return nil
+		}
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if seen[name] {
+			return
+		}
+		if !matches(name, typed) && !matches(b.catalog.name(name, 1), typed) {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	for _, owner := range owners {
+		recs, err := storage.loadRecords(owner)
+		if err != nil {
+			log.Printf("error suggesting items from %v: %v\n", owner, err)
+			return nil
+		}
+		if forSale {
+			recs = recs.forSale()
+		}
+		for _, r := range recs {
+			add(r.name)
+		}
+	}
+	if all {
+		descriptions, err := storage.loadDescriptions()
+		if err != nil {
+			log.Printf("error suggesting items: %v\n", err)
+			return nil
+		}
+		for name := range descriptions {
+			add(name)
+		}
+	}
+	sort.Strings(names)
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, name := range names {
+		if len(choices) == maxChoices {
+			break
+		}
+		choices = appendChoice(choices, b.catalog.name(name, 1), name)
+	}
+	return choices
+}
+
+// ownerChoices suggests the named owners and inventories whose label contains
+// typed.
+func (b backpack) ownerChoices(
+	typed string,
+	st settings,
+	label func(string) string,
+) []*discordgo.ApplicationCommandOptionChoice {
+	owners, err := b.storage().owners()
+	if err != nil {
+		log.Printf("error suggesting owners: %v\n", err)
+		return nil
+	}
+	for _, name := range st.Owners {
+		if !contains(owners, name) {
+			owners = append(owners, name)
+		}
+	}
+	sort.Strings(owners)
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, owner := range owners {
+		if len(choices) == maxChoices {
+			break
+		}
+		name := label(owner)
+		if !matches(name, typed) && !matches(owner, typed) {
+			continue
+		}
+		choices = appendChoice(choices, name, owner)
+	}
+	return choices
+}
+
+// labelChoices suggests the categories, rarities, and tags in the catalog which
+// contain typed.
+func (b backpack) labelChoices(typed string) []*discordgo.ApplicationCommandOptionChoice {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, label := range b.catalog.labels() {
+		if len(choices) == maxChoices {
+			break
+		}
+		if !matches(label, typed) {
+			continue
+		}
+		choices = appendChoice(choices, label, label)
+	}
+	return choices
+}
+
+// appendChoice adds a suggestion to choices with its name shortened to fit.
+// Values too long for discord are left out as they can't be shortened.
+func appendChoice(
+	choices []*discordgo.ApplicationCommandOptionChoice,
+	name, value string,
+) []*discordgo.ApplicationCommandOptionChoice {
+	if utf8.RuneCountInString(value) > choiceLimit {
+		return choices
+	}
+	return append(choices, &discordgo.ApplicationCommandOptionChoice{
+		Name:  truncate(name, choiceLimit),
+		Value: value,
+	})
+}
+
+// matches reports whether name contains typed, ignoring case.
+func matches(name, typed string) bool {
+	return strings.Contains(
+		strings.ToLower(name),
+		strings.ToLower(strings.TrimSpace(typed)),
+	)
+}
+
+// stateLabel returns a function which names channel, user, and role mentions
+// using the session's cache. Owners which aren't cached are left as is.
+func stateLabel(s *discordgo.Session, guildID string) func(string) string {
+	return func(owner string) string {
+		m := mentionPattern.FindStringSubmatch(owner)
+		if m == nil || s.State == nil {
+			return owner
+		}
+		switch m[1] {
+		case "#":
+			if c, err := s.State.Channel(m[2]); err == nil {
+				return "#" + c.Name
+			}
+		case "@", "@!":
+			if member, err := s.State.Member(guildID, m[2]); err == nil {
+				if member.Nick != "" {
+					return "@" + member.Nick
+				}
+				if member.User != nil {
+					return "@" + member.User.Username
+				}
+			}
+		case "@&":
+			if r, err := s.State.Role(guildID, m[2]); err == nil {
+				return "@" + r.Name
+			}
+		}
+		return owner
+	}
+}
@@ -0,0 +1,154 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestSuggest(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"seller.csv":      "10,apple,5\n2,arrow,-1\n1,longsword,20",
+		"<#1>.csv":        "3,arrow,-1\n1,shortsword,-1",
+		"<@&2>.csv":       "5,coin,-1",
+		"descriptions.kv": "amulet=A shiny amulet.\n",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := settings{Owners: []string{"seller", "shop"}}
+	label := func(owner string) string {
+		if owner == "<@&2>" {
+			return "@party"
+		}
+		return owner
+	}
+
+	type test struct {
+		subcommand string
+		options    []*discordgo.ApplicationCommandInteractionDataOption
+		want       []string
+	}
+
+	option := func(name, value string, focused bool) *discordgo.ApplicationCommandInteractionDataOption {
+		return &discordgo.ApplicationCommandInteractionDataOption{
+			Name:    name,
+			Type:    discordgo.ApplicationCommandOptionString,
+			Value:   value,
+			Focused: focused,
+		}
+	}
+
+	tests := []test{
+		{
+			subcommand: "buy",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("seller", "seller", false),
+				option("item", "", true),
+			},
+			want: []string{"apple", "longsword"},
+		},
+		{
+			subcommand: "remove",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("item", "SWO", true),
+			},
+			want: []string{"shortsword"},
+		},
+		{
+			subcommand: "remove",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("owner", "seller", false),
+				option("item", "a", true),
+			},
+			want: []string{"apple", "arrow"},
+		},
+		{
+			subcommand: "describe",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("item", "am", true),
+			},
+			want: []string{"amulet"},
+		},
+		{
+			subcommand: "buy",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("seller", "", true),
+			},
+			want: []string{"<#1>", "<@&2>", "seller", "shop"},
+		},
+		{
+			subcommand: "give",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("to", "part", true),
+			},
+			want: []string{"<@&2>"},
+		},
+		{
+			subcommand: "give",
+			options: []*discordgo.ApplicationCommandInteractionDataOption{
+				option("quantity", "1", true),
+			},
+			want: nil,
+		},
+	}
+
+	b := backpack{dir: dir}
+	for _, tc := range tests {
+		data := discordgo.ApplicationCommandInteractionData{
+			Name: invCommand.Name,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{
+					Name:    tc.subcommand,
+					Type:    discordgo.ApplicationCommandOptionSubCommand,
+					Options: tc.options,
+				},
+			},
+		}
+		var got []string
+		for _, c := range b.suggest(data, "<#1>", "<#1>", st, label) {
+			got = append(got, c.Value.(string))
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%v: want %v got %v", tc.subcommand, tc.want, got)
+		}
+	}
+}
+
+func TestAppendChoice(t *testing.T) {
+	long := strings.Repeat("é", choiceLimit+1)
+	tests := []struct {
+		name, value string
+		want        bool
+	}{
+		{"sword", "sword", true},
+		{long, "sword", true},
+		{"sword", long, false},
+	}
+	for _, tc := range tests {
+		choices := appendChoice(nil, tc.name, tc.value)
+		if (len(choices) == 1) != tc.want {
+			t.Fatalf("%v: want choice %v got %v", tc.value, tc.want, choices)
+		}
+		if !tc.want {
+			continue
+		}
+		if n := utf8.RuneCountInString(choices[0].Name); n > choiceLimit {
+			t.Fatalf("%v: name is %v characters long", tc.value, n)
+		}
+		if choices[0].Value != tc.value {
+			t.Fatalf("want value %v got %v", tc.value, choices[0].Value)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// bulkLimit is the most lines a bulk request may have.
+const bulkLimit = 100
+
+// parseLine reads a single line of a bulk request such as "1 longsword 15".
+// Like add and remove, the count comes first and the price last, both are
+// optional, and a missing name means the least valuable coin of v's currency.
+// Names are resolved through v's catalog.
+func parseLine(line string, v view) (record, error) {
+	fields := strings.Fields(line)
+	count := 1
+	if len(fields) > 0 {
+		if n, err := strconv.Atoi(strings.ReplaceAll(fields[0], ",", "")); err == nil {
+			count = n
+			fields = fields[1:]
+		}
+	}
+	if count <= 0 {
+		return record{}, fmt.Errorf("the count must be above 0")
+	}
+
+	// The longest ending which reads as a price is the price, as long as
+	// something is left for the name.
+	price := money(Unchanged)
+	for i := 1; i < len(fields); i++ {
+		p, err := v.currency.parse(strings.Join(fields[i:], " "))
+		if err != nil {
+			continue
+		}
+		price = p
+		if price < 0 {
+			price = NotForSale
+		}
+		fields = fields[:i]
+		break
+	}
+
+	name := v.currency.base()
+	if len(fields) > 0 {
+		name = v.catalog.resolve(normalizeName(strings.Join(fields, " ")))
+	}
+	return record{count: count, name: name, price: price}, nil
+}
+
+// parseList reads every line of a bulk request. Lines may also be separated by
+// semicolons so a list fits in a single command option. Blank lines are
+// skipped. All of the lines are read before returning so every mistake can be
+// reported at once.
+func parseList(list string, v view) (records, []string) {
+	var recs records
+	var problems []string
+	lines := strings.Split(strings.ReplaceAll(list, ";", "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		r, err := parseLine(line, v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf(
+				"Line %v \"%v\": %v",
+				i+1,
+				strings.TrimSpace(line),
+				err,
+			))
+			continue
+		}
+		recs = append(recs, r)
+	}
+	return recs, problems
+}
+
+// bulkModify adds or removes every item in a list, one item per line, from
+// owner as a single transaction. If any line can't be read or removed nothing
+// is changed.
+func (b backpack) bulkModify(list, owner, op string) string {
+	log.Println(owner, "bulk", op, strings.ReplaceAll(list, "\n", "; "))
+	if op != "add" && op != "remove" {
+		return "You can only add or remove items in bulk."
+	}
+
+	recs, problems := parseList(list, b.view())
+	if len(recs) == 0 && len(problems) == 0 {
+		return "You forgot to list any items."
+	}
+	if len(recs)+len(problems) > bulkLimit {
+		return fmt.Sprintf("You can only list up to %v items at once.", bulkLimit)
+	}
+	if op == "remove" {
+		for _, r := range recs {
+			if r.price != Unchanged {
+				problems = append(problems, fmt.Sprintf(
+					"%v: you can't set a price while removing",
+					r.display(b.view()),
+				))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return "Nothing was changed.\n" + strings.Join(problems, "\n")
+	}
+
+	tx := b.begin(op, owner)
+	defer tx.release()
+
+	var grew bool
+	// The latest state of each item, in the order they were listed.
+	var listed []string
+	latest := make(map[string]record)
+	for _, r := range recs {
+		change := r
+		if op == "remove" {
+			change.count = -r.count
+		}
+		updated, old, err := tx.updateRecord(change, owner, false)
+		if _, ok := err.(*declinedError); ok {
+			problems = append(problems, fmt.Sprintf(
+				"%v does not have %v to remove",
+				owner,
+				record{count: r.count, name: r.name, price: Unchanged}.display(b.view()),
+			))
+			continue
+		} else if _, ok := err.(*overflowError); ok {
+			return OverflowMessage
+		} else if err != nil {
+			log.Println(err)
+			return FatalMessage
+		}
+		if updated.count > old.count {
+			grew = true
+		}
+		if _, ok := latest[r.name]; !ok {
+			listed = append(listed, r.name)
+		}
+		latest[r.name] = updated
+	}
+	if len(problems) > 0 {
+		return "Nothing was changed.\n" + strings.Join(problems, "\n")
+	}
+
+	var warning string
+	if grew {
+		var declined bool
+		var err error
+		warning, declined, err = b.checkEncumbrance(tx, owner)
+		if declined {
+			return warning
+		} else if err != nil {
+			log.Println(err)
+			return FatalMessage
+		}
+	}
+	if err := tx.commit(); err != nil {
+		log.Printf("error in bulk %v request: %v\n", op, err)
+		return FatalMessage
+	}
+
+	var response bytes.Buffer
+	verb := "Added"
+	if op == "remove" {
+		verb = "Removed"
+	}
+	var changes []string
+	for _, r := range recs {
+		changes = append(changes, r.display(b.view()))
+	}
+	response.WriteString(fmt.Sprintf("%v %v\n", verb, strings.Join(changes, ", ")))
+
+	// Summarize each item once, even if it was listed more than once.
+	var has []string
+	for _, name := range listed {
+		has = append(has, latest[name].display(b.view()))
+	}
+	response.WriteString(fmt.Sprintf("%v has %v", owner, strings.Join(has, ", ")))
+	if warning != "" {
+		response.WriteString("\n" + warning)
+	}
+	return response.String()
+}
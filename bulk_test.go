@@ -0,0 +1,156 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	type test struct {
+		line string
+		v    view
+		want record
+		err  bool
+	}
+	tests := []test{
+		{line: "3 arrows", want: record{count: 3, name: "arrow", price: Unchanged}},
+		{line: "1 longsword 15", want: record{count: 1, name: "longsword", price: 1500}},
+		{line: "longsword $1.50", want: record{count: 1, name: "longsword", price: 150}},
+		{line: "50 coin", want: record{count: 50, name: "coin", price: Unchanged}},
+		{line: "50", want: record{count: 50, name: "coin", price: Unchanged}},
+		{line: "1,000 regular arrows", want: record{count: 1000, name: "regular arrow", price: Unchanged}},
+		{
+			line: "2 healing potions 2gp 5sp",
+			v:    view{currency: testCurrency},
+			want: record{count: 2, name: "healing potion", price: 25000},
+		},
+		{
+			line: "hp potion",
+			v: view{catalog: catalog{
+				"healing potion": {Aliases: []string{"hp potion"}},
+			}},
+			want: record{count: 1, name: "healing potion", price: Unchanged},
+		},
+		{line: "0 arrows", err: true},
+		{line: "-2 arrows", err: true},
+	}
+	for _, tc := range tests {
+		got, err := parseLine(tc.line, tc.v)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("%q: want error got %v", tc.line, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tc.line, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%q: want %+v got %+v", tc.line, tc.want, got)
+		}
+	}
+}
+
+func TestBulkModify(t *testing.T) {
+	type test struct {
+		op    string
+		list  string
+		begin string
+
+		wantReply string
+		want      string
+	}
+
+	tests := []test{
+		{
+			op:    "add",
+			list:  "3 arrows\n1 longsword 15\n\n50 coin",
+			begin: "10,arrow,-1",
+			wantReply: "Added 3 Arrows, 1 Longsword for sale for $15, 50 Coins\n" +
+				"owner has 13 Arrows, 1 Longsword for sale for $15, 50 Coins",
+			want: "13,arrow,-1\n1,longsword,15\n50,coin,-1",
+		},
+		{
+			op:    "add",
+			list:  "2 arrows; 2 arrows",
+			begin: "",
+			wantReply: "Added 2 Arrows, 2 Arrows\n" +
+				"owner has 4 Arrows",
+			want: "4,arrow,-1",
+		},
+		{
+			op:    "remove",
+			list:  "3 arrows\n5 coins",
+			begin: "10,arrow,-1\n5,coin,-1",
+			wantReply: "Removed 3 Arrows, 5 Coins\n" +
+				"owner has 7 Arrows, 0 Coins",
+			want: "7,arrow,-1\n0,coin,-1",
+		},
+		{
+			op:    "remove",
+			list:  "3 arrows\n1 longsword\n20 coins",
+			begin: "10,arrow,-1\n5,coin,-1",
+			wantReply: "Nothing was changed.\n" +
+				"owner does not have 1 Longsword to remove\n" +
+				"owner does not have 20 Coins to remove",
+			want: "10,arrow,-1\n5,coin,-1",
+		},
+		{
+			op:    "add",
+			list:  "3 arrows\n0 longswords\n-1 shields",
+			begin: "10,arrow,-1",
+			wantReply: "Nothing was changed.\n" +
+				"Line 2 \"0 longswords\": the count must be above 0\n" +
+				"Line 3 \"-1 shields\": the count must be above 0",
+			want: "10,arrow,-1",
+		},
+		{
+			op:    "remove",
+			list:  "3 arrows 5",
+			begin: "10,arrow,-1",
+			wantReply: "Nothing was changed.\n" +
+				"3 Arrows for sale for $5: you can't set a price while removing",
+			want: "10,arrow,-1",
+		},
+		{
+			op:        "add",
+			list:      "\n ; \n",
+			begin:     "10,arrow,-1",
+			wantReply: "You forgot to list any items.",
+			want:      "10,arrow,-1",
+		},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "owner.csv")
+		if err := os.WriteFile(path, []byte(tc.begin), 0600); err != nil {
+			t.Fatal(err)
+		}
+		b := backpack{dir: dir}
+
+		reply := b.bulkModify(tc.list, "owner", tc.op)
+		if reply != tc.wantReply {
+			t.Fatalf(
+				"incorrect reply:\nwant:\n%v\ngot:\n%v\n",
+				tc.wantReply,
+				reply,
+			)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(got)) != tc.want {
+			t.Fatalf(
+				"incorrect inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.want,
+				string(got),
+			)
+		}
+	}
+}
@@ -30,7 +30,7 @@ func (b backpack) buyItem(count int, item, buyer, seller string) string {
 	if item == "" {
 		return "You forgot to request an item."
 	}
-	if item == "coins" || item == "" {
+	if item == "coins" || b.currency.isMoney(normalizeName(item)) {
 		return "You can't buy coins silly!"
 	}
 
@@ -43,8 +43,35 @@ func (b backpack) buyItem(count int, item, buyer, seller string) string {
 			"Try again with: \"%v\"", fixed)
 	}
 
+	var response bytes.Buffer
+
+	// Every change is staged in a transaction so nothing is written unless
+	// the whole purchase goes through.
+	tx := b.begin("buy", buyer, seller)
+	defer tx.release()
+
+	// Find the item the buyer meant among the seller's items for sale.
+	name := b.itemID(item)
+	recs, err := tx.loadRecords(seller)
+	if err != nil {
+		log.Println(err)
+		return FatalMessage
+	}
+	if !recs.has(name) {
+		match, suggestions := matchItem(name, recs.forSale())
+		if match != "" {
+			name = match
+		} else if len(suggestions) > 0 {
+			return fmt.Sprintf(
+				"%v does not have %v in stock\n%v",
+				seller,
+				record{count: count, name: name, price: Unchanged}.display(b.view()),
+				didYouMean(suggestions, b.catalog),
+			)
+		}
+	}
+
 	// Prepare the record requests.
-	name := normalizeName(item)
 	itemFromSeller := record{
 		count: -count, // Pass a negative count to seller.
 		name:  name,
@@ -56,12 +83,9 @@ func (b backpack) buyItem(count int, item, buyer, seller string) string {
 		price: Unchanged,
 	}
 
-	var response bytes.Buffer
-
 	// Remove item from seller.
-	sellerUpdated, sellerOld, err := updateRecord(
+	sellerUpdated, sellerOld, err := tx.updateRecord(
 		itemFromSeller,
-		b.dir,
 		seller,
 		false,
 	)
@@ -70,7 +94,7 @@ func (b backpack) buyItem(count int, item, buyer, seller string) string {
 		response.WriteString(fmt.Sprintf(
 			"%v does not have %v in stock\n",
 			seller,
-			itemToBuyer,
+			itemToBuyer.display(b.view()),
 		))
 		response.WriteString("Please choose one of the following items:\n")
 		response.WriteString(b.displayInvetory(seller, true))
@@ -85,97 +109,86 @@ func (b backpack) buyItem(count int, item, buyer, seller string) string {
 	if sellerOld.price == NotForSale {
 		// Transaction declined. Item is not for sale!
 		response.WriteString(
-			fmt.Sprintf("%v does not have %v for sale\n", seller, itemToBuyer),
+			fmt.Sprintf("%v does not have %v for sale\n", seller, itemToBuyer.display(b.view())),
 		)
 		response.WriteString("Please choose one of the following items:\n")
 		response.WriteString(b.displayInvetory(seller, true))
-
-		// Revert seller inventory change!
-		_, _, err := updateRecord(sellerOld, b.dir, seller, true)
-		if err != nil {
-			log.Printf(
-				"error in buy request %v: "+
-					"item was not for sale, but unrolling transaction failed: %v\n",
-				itemToBuyer,
-				err,
-			)
-			return FatalMessage
-		}
 		return response.String()
 	}
 
-	// Remove coins from buyer.
-	sum := itemToBuyer.count * sellerOld.price
-	coinsFromBuyer := record{
-		count: -sum, // Negative to subtract.
-		name:  Coin,
-		price: NotForSale,
+	// Pay the seller with the buyer's coins. Fractions of a coin are
+	// rounded up as there is no smaller coin to pay them with.
+	total, ok := sellerOld.price.times(itemToBuyer.count)
+	if !ok {
+		return OverflowMessage
 	}
-	coinsToSeller := record{
-		count: sum,
-		name:  Coin,
-		price: NotForSale,
+	sum, ok := total.ceil()
+	if !ok {
+		return OverflowMessage
 	}
-	_, buyerOld, err := updateRecord(coinsFromBuyer, b.dir, buyer, false)
+	cost := "$" + strconv.Itoa(sum)
+	if len(b.currency) > 0 {
+		cost = b.currency.formatCoins(sum)
+	}
+	err = tx.pay(b.currency, buyer, seller, sum)
 	if _, ok := err.(*declinedError); ok {
 		// Transaction declined. Buyer doesn't have enough coins.
+		recs, _ := tx.loadRecords(buyer)
 		response.WriteString(
 			fmt.Sprintf("%v has insufficient funds\n", buyer) +
-				fmt.Sprintf("%v costs $%v\n", itemToBuyer, strconv.Itoa(sum)) +
-				fmt.Sprintf("%v only has %v", buyer, buyerOld),
+				fmt.Sprintf("%v costs %v\n", itemToBuyer.display(b.view()), cost) +
+				fmt.Sprintf("%v only has %v", buyer, b.currency.balance(recs)),
 		)
-
-		// Revert seller inventory change!
-		_, _, err := updateRecord(sellerOld, b.dir, seller, true)
-		if err != nil {
-			log.Printf(
-				"error in buy request %v: "+
-					"buyer lacked coins, but unrolling transaction failed: %v\n",
-				itemToBuyer,
-				err,
-			)
-			return FatalMessage
-		}
 		return response.String()
+	} else if _, ok := err.(*overflowError); ok {
+		return OverflowMessage
 	} else if err != nil {
 		// Fatal error.
 		log.Println(err)
 		return FatalMessage
 	}
 
-	// Give coins to seller.
-	_, _, err = updateRecord(coinsToSeller, b.dir, seller, false)
-	if err != nil {
+	// Give item to buyer.
+	_, _, err = tx.updateRecord(itemToBuyer, buyer, false)
+	if _, ok := err.(*overflowError); ok {
+		return OverflowMessage
+	} else if err != nil {
 		log.Printf("error in buy request %v %v: "+
-			"item removed from seller, coins removed from buyer,"+
-			"but failed to give coins to seller\n", count, item)
+			"failed to give %v to buyer: %v\n", count, item, itemToBuyer, err)
 		return FatalMessage
 	}
 
-	// Give item to buyer.
-	_, _, err = updateRecord(itemToBuyer, b.dir, buyer, false)
-	if err != nil {
-		log.Printf("error in buy request %v %v: "+
-			"item removed from seller, coins removed from buyer,"+
-			"but failed to give %v to buyer\n", count, item, itemToBuyer)
+	warning, declined, err := b.checkEncumbrance(tx, buyer)
+	if declined {
+		return warning
+	} else if err != nil {
+		log.Printf("error in buy request %v %v: %v\n", count, item, err)
+		return FatalMessage
+	}
+
+	if err := tx.commit(); err != nil {
+		log.Printf("error in buy request %v %v: %v\n", count, item, err)
 		return FatalMessage
 	}
 
 	response.WriteString(fmt.Sprintf(
-		"%v bought %v for $%v\n",
+		"%v bought %v for %v\n",
 		buyer,
-		itemToBuyer,
-		sum,
+		itemToBuyer.display(b.view()),
+		cost,
 	))
 	response.WriteString(fmt.Sprintf(
 		"%v has %v\n",
 		buyer,
-		itemToBuyer,
+		itemToBuyer.display(b.view()),
 	))
 	response.WriteString(fmt.Sprintf(
 		"%v has %v",
 		seller,
-		sellerUpdated,
+		sellerUpdated.display(b.view()),
 	))
+	if warning != "" {
+		response.WriteString("\n" + warning)
+	}
 	return response.String()
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -162,6 +163,27 @@ func TestBuyItem(t *testing.T) {
 			buyerWant:  "50,coin,-1",
 			sellerWant: "10,coins,1\n20,apple,1\n18,arrow,4\n1,sword,40",
 		},
+		{
+			count:  2,
+			item:   "aples",
+			coins:  "50",
+			seller: "20,apple,1\n5,arrow,2",
+			wantReply: "buyer bought 2 Apples for $2\n" +
+				"buyer has 2 Apples\n" +
+				"seller has 18 Apples for sale for $1",
+			buyerWant:  "48,coin,-1\n2,apple,-1",
+			sellerWant: "18,apple,1\n5,arrow,2\n2,coin,-1",
+		},
+		{
+			count:  1,
+			item:   "sword",
+			coins:  "50",
+			seller: "1,longsword,10\n1,short sword,5\n3,apple,1",
+			wantReply: "seller does not have 1 Sword in stock\n" +
+				"Did you mean: Longsword, Short sword?",
+			buyerWant:  "50,coin,-1",
+			sellerWant: "1,longsword,10\n1,short sword,5\n3,apple,1",
+		},
 	}
 
 	for _, tc := range tests {
@@ -223,3 +245,30 @@ func TestBuyItem(t *testing.T) {
 		}
 	}
 }
+
+func TestBuyItemLargeShop(t *testing.T) {
+	dir := t.TempDir()
+	var shop strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&shop, "1,item %03d,5\n", i)
+	}
+	err := os.WriteFile(filepath.Join(dir, "seller.csv"), []byte(shop.String()), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "buyer.csv"), []byte("50,coin,-1"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := backpack{dir: dir}
+	for _, item := range []string{"item 007", "longsword"} {
+		reply := b.buyItem(2, item, "buyer", "seller")
+		if len(reply) > messageLimit {
+			t.Fatalf("buying %v replied with %v characters", item, len(reply))
+		}
+		if !strings.Contains(reply, "View seller to see all") {
+			t.Fatalf("buying %v doesn't mention the other pages:\n%v", item, reply)
+		}
+	}
+}
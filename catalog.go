@@ -0,0 +1,272 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// catalogItem describes an item in every inventory of a guild. Records refer to
+// items by their ID, which is the item's normalized name when it was first
+// used, so renaming an item or giving it aliases changes it everywhere.
+type catalogItem struct {
+	// Name is shown instead of the ID. If empty the ID is shown.
+	Name string `json:"name,omitempty"`
+
+	// Aliases are other names which refer to the item.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Weight is how much one of the item weighs.
+	Weight float64 `json:"weight,omitempty"`
+
+	// Category is the kind of item, such as "weapon" or "consumable".
+	Category string `json:"category,omitempty"`
+
+	// Rarity is how rare the item is, such as "uncommon".
+	Rarity string `json:"rarity,omitempty"`
+
+	// Tags are free-form labels used to filter views.
+	Tags []string `json:"tags,omitempty"`
+
+	// Description is kept with the guild's other descriptions rather than
+	// in the catalog.
+	Description string `json:"-"`
+}
+
+// empty reports whether the entry holds nothing worth storing.
+func (item catalogItem) empty() bool {
+	return item.Name == "" &&
+		len(item.Aliases) == 0 &&
+		item.Weight == 0 &&
+		item.Category == "" &&
+		item.Rarity == "" &&
+		len(item.Tags) == 0
+}
+
+// catalog maps item IDs to their entries. Items which have never been
+// renamed, aliased, or described have no entry and are shown by their ID.
+type catalog map[string]catalogItem
+
+// resolve returns the ID of the item a user named. name should already be
+// normalized. Names and aliases are matched ignoring case. Names which are not
+// known are their own ID.
+func (c catalog) resolve(name string) string {
+	if _, ok := c[name]; ok {
+		return name
+	}
+	for id := range c {
+		if c.calls(id, name) {
+			return id
+		}
+	}
+	return name
+}
+
+// calls reports whether the item with id is called name by its ID, display
+// name, or an alias.
+func (c catalog) calls(id, name string) bool {
+	if strings.EqualFold(id, name) {
+		return true
+	}
+	item := c[id]
+	if item.Name != "" && strings.EqualFold(normalizeName(item.Name), name) {
+		return true
+	}
+	for _, alias := range item.Aliases {
+		if strings.EqualFold(alias, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// itemID returns the ID of the item a user named.
+func (b backpack) itemID(item string) string {
+	return b.catalog.resolve(normalizeName(item))
+}
+
+// name returns the display name of the item with id.
+func (c catalog) name(id string, count int) string {
+	if item, ok := c[id]; ok && item.Name != "" {
+		return displayName(item.Name, count)
+	}
+	return displayName(id, count)
+}
+
+// view is what a guild needs to show items and prices: its currency and
+// catalog.
+type view struct {
+	currency currency
+	catalog  catalog
+}
+
+// view returns how items and prices are shown in the guild.
+func (b backpack) view() view {
+	return view{currency: b.currency, catalog: b.catalog}
+}
+
+// loadCatalog reads the guild's catalog along with every item's description.
+func (b backpack) loadCatalog() (catalog, error) {
+	storage := b.storage()
+	c, err := storage.loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+	descriptions, err := storage.loadDescriptions()
+	if err != nil {
+		return nil, err
+	}
+	for id, description := range descriptions {
+		item := c[id]
+		item.Description = description
+		c[id] = item
+	}
+	return c, nil
+}
+
+// updateCatalog loads the catalog, passes the entry of id to update, and
+// stores the result. The catalog is locked while update runs. If update
+// returns an error nothing is stored.
+func (b backpack) updateCatalog(id string, update func(c catalog, item *catalogItem) error) (catalogItem, error) {
+	storage := b.storage()
+	unlock := inventoryLocks.lock(dataKey(storage, "catalog"))
+	defer unlock()
+
+	c, err := storage.loadCatalog()
+	if err != nil {
+		return catalogItem{}, err
+	}
+	item := c[id]
+	if err := update(c, &item); err != nil {
+		return item, err
+	}
+	if item.empty() {
+		delete(c, id)
+	} else {
+		c[id] = item
+	}
+	return item, storage.storeCatalog(c)
+}
+
+// errNameTaken is returned when a name already refers to another item.
+var errNameTaken = errors.New("name already taken")
+
+// taken returns the ID of an item other than id which name already refers
+// to.
+func (c catalog) taken(id, name string) (string, bool) {
+	for other := range c {
+		if other == id {
+			continue
+		}
+		if c.calls(other, name) {
+			return other, true
+		}
+	}
+	return "", false
+}
+
+// holders maps the ID of every item held in an inventory, in lower case, to
+// an owner holding it.
+func (b backpack) holders() (map[string]string, error) {
+	storage := b.storage()
+	owners, err := storage.owners()
+	if err != nil {
+		return nil, err
+	}
+	held := make(map[string]string)
+	for _, owner := range owners {
+		recs, err := storage.loadRecords(owner)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range recs {
+			if _, ok := held[strings.ToLower(r.name)]; !ok {
+				held[strings.ToLower(r.name)] = owner
+			}
+		}
+	}
+	return held, nil
+}
+
+// editItem renames an item or changes its aliases. Empty arguments are left
+// alone. When an item is renamed its old name becomes an alias so it can still
+// be used. Names held as another item's ID in some inventory are refused so
+// those records aren't hidden behind this item.
+func (b backpack) editItem(item, name, alias, unalias string) string {
+	log.Println("catalog", item, name, alias, unalias)
+	if item == "" {
+		return "You forgot to request an item."
+	}
+	id := b.catalog.resolve(normalizeName(item))
+	held, err := b.holders()
+	if err != nil {
+		log.Printf("error loading held items: %v\n", err)
+		return FatalMessage
+	}
+
+	var conflict, holder string
+	edited, err := b.updateCatalog(id, func(c catalog, it *catalogItem) error {
+		if name != "" {
+			n := strings.ToLower(normalizeName(name))
+			if other, ok := c.taken(id, n); ok {
+				conflict = other
+				return errNameTaken
+			}
+			if owner, ok := held[n]; ok && n != id {
+				conflict, holder = n, owner
+				return errNameTaken
+			}
+			old := strings.ToLower(normalizeName(c.name(id, 1)))
+			renamed := strings.ToLower(normalizeName(name))
+			if old != id && old != renamed && !contains(it.Aliases, old) {
+				it.Aliases = append(it.Aliases, old)
+			}
+			it.Name = strings.TrimSpace(name)
+		}
+		if alias != "" {
+			a := strings.ToLower(normalizeName(alias))
+			if other, ok := c.taken(id, a); ok {
+				conflict = other
+				return errNameTaken
+			}
+			if owner, ok := held[a]; ok && a != id {
+				conflict, holder = a, owner
+				return errNameTaken
+			}
+			if a != id && !contains(it.Aliases, a) {
+				it.Aliases = append(it.Aliases, a)
+			}
+		}
+		if unalias != "" {
+			it.Aliases = remove(it.Aliases, strings.ToLower(normalizeName(unalias)))
+		}
+		sort.Strings(it.Aliases)
+		return nil
+	})
+	if err == errNameTaken && holder != "" {
+		return fmt.Sprintf(
+			"That name is already used by %v in %v's inventory.",
+			b.catalog.name(conflict, 1),
+			holder,
+		)
+	} else if err == errNameTaken {
+		return fmt.Sprintf(
+			"That name is already used by %v.",
+			b.catalog.name(conflict, 1),
+		)
+	} else if err != nil {
+		log.Printf("error editing catalog item %v: %v\n", id, err)
+		return FatalMessage
+	}
+
+	c := catalog{id: edited}
+	msg := fmt.Sprintf("%v (%v)", c.name(id, 1), id)
+	if len(edited.Aliases) > 0 {
+		msg += " is also called: " + strings.Join(edited.Aliases, ", ")
+	}
+	return msg
+}
@@ -0,0 +1,127 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCatalogResolve(t *testing.T) {
+	c := catalog{
+		"healing potion": {
+			Name:    "Potion of healing",
+			Aliases: []string{"hp potion"},
+		},
+	}
+	tests := map[string]string{
+		"healing potion":    "healing potion",
+		"potion of healing": "healing potion",
+		"hp potion":         "healing potion",
+		"mana potion":       "mana potion",
+	}
+	for name, want := range tests {
+		if got := c.resolve(name); got != want {
+			t.Fatalf("resolve(%q): want %q got %q", name, want, got)
+		}
+	}
+	if got := c.name("healing potion", 1); got != "Potion of healing" {
+		t.Fatalf("name: got %q", got)
+	}
+	if got := c.name("mana potion", 1); got != "Mana potion" {
+		t.Fatalf("name: got %q", got)
+	}
+}
+
+func TestEditItem(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(
+		filepath.Join(dir, "seller.csv"),
+		[]byte("5,healing potion,10"),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "buyer.csv"), []byte("50,coin,-1"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := backpack{dir: dir}
+	reload := func() {
+		b.catalog, err = b.loadCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type test struct {
+		item, name, alias, unalias string
+		want                       string
+	}
+	tests := []test{
+		{
+			item:  "healing potions",
+			alias: "hp potion",
+			want:  "Healing potion (healing potion) is also called: hp potion",
+		},
+		{
+			item: "hp potion",
+			name: "Red potion",
+			want: "Red potion (healing potion) is also called: hp potion",
+		},
+		{
+			item: "red potion",
+			name: "Crimson potion",
+			want: "Crimson potion (healing potion) is also called: " +
+				"hp potion, red potion",
+		},
+		{
+			item:    "crimson potion",
+			unalias: "red potion",
+			want:    "Crimson potion (healing potion) is also called: hp potion",
+		},
+		{
+			item:  "mana potion",
+			alias: "Crimson Potion",
+			want:  "That name is already used by Crimson potion.",
+		},
+		{
+			item:  "crimson potion",
+			alias: "coins",
+			want:  "That name is already used by Coin in buyer's inventory.",
+		},
+		{
+			item: "crimson potion",
+			name: "Coin",
+			want: "That name is already used by Coin in buyer's inventory.",
+		},
+	}
+	for _, tc := range tests {
+		reload()
+		got := b.editItem(tc.item, tc.name, tc.alias, tc.unalias)
+		if got != tc.want {
+			t.Fatalf("editItem(%q): want %q got %q", tc.item, tc.want, got)
+		}
+	}
+
+	// The item's new name and aliases work in every inventory.
+	reload()
+	reply := b.buyItem(2, "hp potions", "buyer", "seller")
+	want := "buyer bought 2 Crimson potions for $20\n" +
+		"buyer has 2 Crimson potions\n" +
+		"seller has 3 Crimson potions for sale for $10"
+	if reply != want {
+		t.Fatalf("incorrect reply:\nwant:\n%v\ngot:\n%v\n", want, reply)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "buyer.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(got)) != "30,coin,-1\n2,healing potion,-1" {
+		t.Fatalf("incorrect buyer inventory:\n%v", string(got))
+	}
+}
@@ -3,8 +3,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"strconv"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -15,12 +19,31 @@ import (
 const FatalMessage = "Backpack failed! Contact your local currator for help!"
 
 type backpack struct {
-	dir string
+	dir     string
+	backend string
+
+	// actor is the ID of the user running the current command and
+	// interaction is the ID of the command's interaction. Both are recorded
+	// in the ledger.
+	actor       string
+	interaction string
+
+	// currency is the money used in the guild and catalog names its items.
+	currency currency
+	catalog  catalog
+
+	// render is how the guild shows inventories and receipts.
+	render string
 }
 
+// dmPermission disables the command in direct messages as inventories belong
+// to a guild.
+var dmPermission = false
+
 var invCommand = discordgo.ApplicationCommand{
-	Name:        "inv",
-	Description: "Manage Inventories",
+	Name:         "inv",
+	Description:  "Manage Inventories",
+	DMPermission: &dmPermission,
 	Options: []*discordgo.ApplicationCommandOption{
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
@@ -29,10 +52,11 @@ var invCommand = discordgo.ApplicationCommand{
 			Required:    false,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "item",
-					Description: "What item to describe",
-					Required:    true,
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "What item to describe",
+					Required:     true,
+					Autocomplete: true,
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
@@ -42,102 +66,555 @@ var invCommand = discordgo.ApplicationCommand{
 				},
 			},
 		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "item",
+			Description: "Rename, label, or weigh an item in every inventory",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "What item to change",
+					Required:     true,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "The item's new name",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "alias",
+					Description: "Another name for the item",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "unalias",
+					Description: "A name to stop using for the item",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "weight",
+					Description: "How much one of the item weighs, 0 for nothing",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "category",
+					Description: "The kind of item such as weapon, none to remove it",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "rarity",
+					Description: "How rare the item is, none to remove it",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "tag",
+					Description: "A label to filter views by",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "untag",
+					Description: "A label to remove from the item",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "capacity",
+			Description: "Set how much an inventory can carry",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "capacity",
+					Description: "The most the inventory can carry, -1 for no limit",
+					Required:    true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Whose capacity to set",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "encumbrance",
+					Description: "What happens to anyone who carries too much",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "warn", Value: encumbranceWarn},
+						{Name: "decline", Value: encumbranceDecline},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "register",
+			Description: "Register a named inventory such as a shop",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "The name of the inventory",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "role",
+			Description: "Set what members with a role may do",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "role",
+					Description: "The role to set",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "level",
+					Description: "What members with the role may do",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "gamemaster", Value: gamemaster.String()},
+						{Name: "player", Value: player.String()},
+						{Name: "none", Value: noAccess.String()},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "currency",
+			Description: "Add, change, or remove a coin",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "The name of the coin such as gold piece",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "symbol",
+					Description: "The symbol used in prices such as gp",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "value",
+					Description: "How many of the least valuable coin it is worth, 0 to remove it",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "render",
+			Description: "Choose how inventories and receipts are shown",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "style",
+					Description: "Text tables or embeds",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "text", Value: renderText},
+						{Name: "embed", Value: renderEmbed},
+					},
+				},
+			},
+		},
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
 			Name:        "view",
 			Description: "View an inventory",
 			Required:    false,
 			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Whose inventory to view",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "filter",
+					Description:  "Only show items with this category, rarity, or tag",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "group",
+					Description: "Split the items into sections",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "category", Value: groupCategory},
+						{Name: "rarity", Value: groupRarity},
+					},
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "owner",
-					Description: "Whose inventory to view",
+					Name:        "sort",
+					Description: "The order to show the items in",
 					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "name", Value: sortName},
+						{Name: "quantity", Value: sortQuantity},
+						{Name: "price", Value: sortPrice},
+						{Name: "total value", Value: sortValue},
+						{Name: "recently changed", Value: sortRecent},
+					},
 				},
 			},
 		},
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
-			Name:        "add",
-			Description: "Add an item",
+			Name:        "sort",
+			Description: "Set the order an inventory is shown in",
 			Required:    false,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "owner",
-					Description: "Whose inventory to add to",
+					Name:        "sort",
+					Description: "The order to show the items in, or leave out for the order they were added",
 					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "name", Value: sortName},
+						{Name: "quantity", Value: sortQuantity},
+						{Name: "price", Value: sortPrice},
+						{Name: "total value", Value: sortValue},
+						{Name: "recently changed", Value: sortRecent},
+					},
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Whose inventory to sort",
+					Required:     false,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "history",
+			Description: "View recent changes to an inventory or item",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Whose changes to view",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "Which item's changes to view",
+					Required:     false,
+					Autocomplete: true,
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "quantity",
-					Description: "The number of items to add",
+					Name:        "page",
+					Description: "The page of changes to view",
 					Required:    false,
 				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "sell",
+			Description: "Sell an item back to a merchant",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "seller",
+					Description:  "Who's selling the item",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "merchant",
+					Description:  "Who's buying the item back",
+					Required:     false,
+					Autocomplete: true,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "item",
-					Description: "The name of the item to add",
+					Name:        "quantity",
+					Description: "The number of items to sell",
 					Required:    false,
 				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "The name of the item to sell",
+					Required:     false,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "offer",
+			Description: "Set what a merchant pays to buy items back",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Which merchant buys the item",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "The name of the item to buy back",
+					Required:     false,
+					Autocomplete: true,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
 					Name:        "price",
-					Description: "The price of the item to add",
+					Description: "The price paid for each item, 0 to stop buying it",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "budget",
+					Description: "The most the merchant spends, -1 for no limit",
 					Required:    false,
 				},
 			},
 		},
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
-			Name:        "remove",
-			Description: "Remove an item",
+			Name:        "trade",
+			Description: "Offer to trade items with another inventory",
 			Required:    false,
 			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "to",
+					Description:  "Who the trade is offered to",
+					Required:     true,
+					Autocomplete: true,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "owner",
-					Description: "Whose inventory to remove from",
+					Name:        "give",
+					Description: "What you give, such as \"2 arrows, 10\"",
 					Required:    false,
 				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "want",
+					Description: "What you want in return",
+					Required:    false,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "from",
+					Description:  "Who's offering the trade",
+					Required:     false,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "give",
+			Description: "Give an item or coins to another inventory",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "from",
+					Description:  "Who's giving the item",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "to",
+					Description:  "Who's receiving the item",
+					Required:     false,
+					Autocomplete: true,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
 					Name:        "quantity",
-					Description: "The number of items to remove",
+					Description: "The number of items to give",
 					Required:    false,
 				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "The name of the item to give",
+					Required:     false,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "undo",
+			Description: "Undo your last transactions",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "item",
-					Description: "The name of the item to remove",
+					Name:        "quantity",
+					Description: "The number of transactions to undo",
 					Required:    false,
 				},
 			},
 		},
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
-			Name:        "set",
-			Description: "Set the quantity or price of an item",
+			Name:        "add",
+			Description: "Add an item",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Whose inventory to add to",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "quantity",
+					Description: "The number of items to add",
+					Required:    false,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "The name of the item to add",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "price",
+					Description: "The price of the item to add",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "bulk",
+			Description: "Add or remove a list of items at once",
 			Required:    false,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "owner",
-					Description: "Whose inventory to edit",
+					Name:        "action",
+					Description: "Whether to add or remove the items",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "add", Value: "add"},
+						{Name: "remove", Value: "remove"},
+					},
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Whose inventory to change",
+					Required:     false,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "items",
+					Description: "Items separated by semicolons, or leave out to write one per line",
 					Required:    false,
 				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "remove",
+			Description: "Remove an item",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Whose inventory to remove from",
+					Required:     false,
+					Autocomplete: true,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
 					Name:        "quantity",
-					Description: "The resultant number of items",
+					Description: "The number of items to remove",
 					Required:    false,
 				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "The name of the item to remove",
+					Required:     false,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "set",
+			Description: "Set the quantity or price of an item",
+			Required:    false,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "owner",
+					Description:  "Whose inventory to edit",
+					Required:     false,
+					Autocomplete: true,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "item",
-					Description: "The name of the item",
+					Name:        "quantity",
+					Description: "The resultant number of items",
 					Required:    false,
 				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "The name of the item",
+					Required:     false,
+					Autocomplete: true,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
 					Name:        "price",
@@ -153,16 +630,18 @@ var invCommand = discordgo.ApplicationCommand{
 			Required:    false,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "buyer",
-					Description: "Who's buying the item",
-					Required:    false,
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "buyer",
+					Description:  "Who's buying the item",
+					Required:     false,
+					Autocomplete: true,
 				},
 				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "seller",
-					Description: "Who's selling the item",
-					Required:    false,
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "seller",
+					Description:  "Who's selling the item",
+					Required:     false,
+					Autocomplete: true,
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
@@ -171,10 +650,11 @@ var invCommand = discordgo.ApplicationCommand{
 					Required:    false,
 				},
 				{
-					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "item",
-					Description: "The name of the item to buy",
-					Required:    false,
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "item",
+					Description:  "The name of the item to buy",
+					Required:     false,
+					Autocomplete: true,
 				},
 			},
 		},
@@ -184,10 +664,32 @@ var invCommand = discordgo.ApplicationCommand{
 // commandHandler is called (due to the AddHandler above) every time a new
 // command is sent on any channel that the authenticated bot has access to.
 func (b backpack) commandHandler(s *discordgo.Session, m *discordgo.InteractionCreate) {
+	switch m.Type {
+	case discordgo.InteractionApplicationCommand:
+	case discordgo.InteractionMessageComponent:
+		b.componentHandler(s, m)
+		return
+	case discordgo.InteractionModalSubmit:
+		b.modalHandler(s, m)
+		return
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		if m.ApplicationCommandData().Name == invCommand.Name {
+			b.autocompleteHandler(s, m)
+		}
+		return
+	default:
+		return
+	}
 	if m.ApplicationCommandData().Name != invCommand.Name {
 		return
 	}
 
+	b, st, a, err := b.prepare(m)
+	if err != nil {
+		say(err.Error(), s, m)
+		return
+	}
+
 	if len(m.ApplicationCommandData().Options) != 1 {
 		say("WTF ARE YOU DOING!?!?!", s, m)
 		return
@@ -196,55 +698,451 @@ func (b backpack) commandHandler(s *discordgo.Session, m *discordgo.InteractionC
 
 	options := mapOptions(subcommand.Options)
 	defaultOwner := fmt.Sprintf("<#%v>", m.ChannelID)
+	payer := a.payer(defaultOwner)
+
+	if subcommand.Name == "role" {
+		if !a.admin {
+			say(DeniedMessage+" Only server managers can set role levels.", s, m)
+			return
+		}
+		say(b.setRoleLevel(
+			getStringOrDefault(options, "role", ""),
+			getStringOrDefault(options, "level", ""),
+		), s, m)
+		return
+	}
+
+	if !a.canView() {
+		say(DeniedMessage, s, m)
+		return
+	}
 
 	if subcommand.Name == "describe" {
 		item := getStringOrDefault(options, "item", "")
 		description := getStringOrDefault(options, "description", "")
+		if description == "" && a.canEdit() {
+			// Let gamemasters write the description in a form, which
+			// unlike an option allows more than one line.
+			modal := b.descriptionModal(b.itemID(item))
+			if item != "" && len(modal.CustomID) <= customIDLimit {
+				s.InteractionRespond(m.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseModal,
+					Data: modal,
+				})
+				return
+			}
+		}
 		if description == "" {
 			// Print the description.
 			say(b.description(item), s, m)
+		} else if !a.canEdit() {
+			say(DeniedMessage+" Only gamemasters can describe items.", s, m)
 		} else {
 			say(b.setDescription(item, description), s, m)
 		}
 		return
 	}
 
+	if subcommand.Name == "item" {
+		if !a.canEdit() {
+			say(DeniedMessage+" Only gamemasters can change items.", s, m)
+			return
+		}
+		var reply []string
+		if _, ok := options["weight"]; ok {
+			weight, err := strconv.ParseFloat(
+				getStringOrDefault(options, "weight", ""),
+				64,
+			)
+			if err != nil {
+				say("Invalid weight. Please use a number.", s, m)
+				return
+			}
+			reply = append(reply, b.setWeight(
+				getStringOrDefault(options, "item", ""),
+				weight,
+			))
+		}
+		_, tag := options["tag"]
+		_, untag := options["untag"]
+		_, category := options["category"]
+		_, rarity := options["rarity"]
+		if category || rarity || tag || untag {
+			reply = append(reply, b.tagItem(
+				getStringOrDefault(options, "item", ""),
+				getStringOrDefault(options, "category", ""),
+				getStringOrDefault(options, "rarity", ""),
+				getStringOrDefault(options, "tag", ""),
+				getStringOrDefault(options, "untag", ""),
+			))
+		}
+		// Rename or alias the item if asked, or show it if nothing else
+		// was.
+		_, name := options["name"]
+		_, alias := options["alias"]
+		_, unalias := options["unalias"]
+		if len(reply) == 0 || name || alias || unalias {
+			reply = append(reply, b.editItem(
+				getStringOrDefault(options, "item", ""),
+				getStringOrDefault(options, "name", ""),
+				getStringOrDefault(options, "alias", ""),
+				getStringOrDefault(options, "unalias", ""),
+			))
+		}
+		say(strings.Join(reply, "\n"), s, m)
+		return
+	}
+
+	if subcommand.Name == "capacity" {
+		if !a.canEdit() {
+			say(DeniedMessage+" Only gamemasters can set capacities.", s, m)
+			return
+		}
+		owner, err := getOwnerOrDefault(options, "owner", defaultOwner, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		capacity, err := strconv.ParseFloat(
+			getStringOrDefault(options, "capacity", ""),
+			64,
+		)
+		if err != nil || math.IsNaN(capacity) || math.IsInf(capacity, 0) {
+			say("Invalid capacity. Please use a number.", s, m)
+			return
+		}
+		say(b.setCapacity(
+			capacity,
+			owner,
+			getStringOrDefault(options, "encumbrance", ""),
+		), s, m)
+		return
+	}
+
+	if subcommand.Name == "register" {
+		if !a.canEdit() {
+			say(DeniedMessage+" Only gamemasters can register owners.", s, m)
+			return
+		}
+		say(b.registerOwner(getStringOrDefault(options, "name", "")), s, m)
+		return
+	}
+
+	if subcommand.Name == "render" {
+		if !a.canEdit() {
+			say(DeniedMessage+" Only gamemasters can change how inventories are shown.", s, m)
+			return
+		}
+		say(b.setRender(getStringOrDefault(options, "style", "")), s, m)
+		return
+	}
+
+	if subcommand.Name == "currency" {
+		if !a.canEdit() {
+			say(DeniedMessage+" Only gamemasters can change the currency.", s, m)
+			return
+		}
+		value, err := getIntOrDefault(options, "value", 0)
+		if err != nil {
+			say("Invalid value. Please use a whole number.", s, m)
+			return
+		}
+		say(b.setDenomination(
+			getStringOrDefault(options, "name", ""),
+			getStringOrDefault(options, "symbol", ""),
+			value,
+		), s, m)
+		return
+	}
+
 	if subcommand.Name == "view" {
-		say(b.displayInvetory(
-			getStringOrDefault(options, "owner", defaultOwner),
-			false,
+		owner, err := getOwnerOrDefault(options, "owner", defaultOwner, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		showPage(b.viewPage(viewQuery{
+			owner:  owner,
+			filter: getStringOrDefault(options, "filter", ""),
+			group:  getStringOrDefault(options, "group", ""),
+			order:  getStringOrDefault(options, "sort", ""),
+		}, 1, stateLabel(s, m.GuildID)), s, m)
+		return
+	}
+
+	if subcommand.Name == "sort" {
+		owner, err := getOwnerOrDefault(options, "owner", payer, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		if !a.canSpend(owner) {
+			say(DeniedMessage+" You can only sort your own inventory.", s, m)
+			return
+		}
+		say(b.setSort(getStringOrDefault(options, "sort", ""), owner), s, m)
+		return
+	}
+
+	if subcommand.Name == "history" {
+		// History shows every owner unless one is given.
+		var owner string
+		if _, ok := options["owner"]; ok {
+			owner, err = getOwnerOrDefault(options, "owner", "", st)
+			if err != nil {
+				say(err.Error(), s, m)
+				return
+			}
+		}
+		page, err := getIntOrDefault(options, "page", 1)
+		if err != nil {
+			say("Invalid page. Please use a whole number.", s, m)
+			return
+		}
+		say(b.history(
+			owner,
+			getStringOrDefault(options, "item", ""),
+			page,
 		), s, m)
 		return
 	}
 
+	if subcommand.Name == "trade" {
+		from, err := getOwnerOrDefault(options, "from", payer, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		to, err := getOwnerOrDefault(options, "to", "", st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		if !a.canSpend(from) {
+			say(DeniedMessage+" You can only trade from your own inventory.", s, m)
+			return
+		}
+		msg, ok := b.proposeTrade(
+			m.ID,
+			from,
+			to,
+			getStringOrDefault(options, "give", ""),
+			getStringOrDefault(options, "want", ""),
+		)
+		if !ok {
+			say(msg, s, m)
+			return
+		}
+		s.InteractionRespond(m.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content:    msg,
+				Components: tradeButtons(m.ID),
+			},
+		})
+		return
+	}
+
 	count, err := getIntOrDefault(options, "quantity", 1)
 	if err != nil {
 		say("Invalid quantity. Please use a whole number.", s, m)
 		return
 	}
-	if subcommand.Name == "buy" {
-		say(b.buyItem(
+	if subcommand.Name == "undo" {
+		say(b.undo(count, a), s, m)
+		return
+	}
+	if subcommand.Name == "sell" {
+		seller, err := getOwnerOrDefault(options, "seller", payer, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		merchant, err := getOwnerOrDefault(options, "merchant", defaultOwner, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		if !a.canSpend(seller) {
+			say(DeniedMessage+" You can only sell from your own inventory.", s, m)
+			return
+		}
+		say(b.sellItem(
 			count,
 			getStringOrDefault(options, "item", ""),
-			getStringOrDefault(options, "buyer", defaultOwner),
-			getStringOrDefault(options, "seller", defaultOwner),
+			seller,
+			merchant,
 		), s, m)
 		return
 	}
+	if subcommand.Name == "give" {
+		from, err := getOwnerOrDefault(options, "from", payer, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		to, err := getOwnerOrDefault(options, "to", defaultOwner, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		if !a.canSpend(from) {
+			say(DeniedMessage+" You can only give from your own inventory.", s, m)
+			return
+		}
+		say(b.giveItem(
+			count,
+			getStringOrDefault(options, "item", b.currency.base()),
+			from,
+			to,
+		), s, m)
+		return
+	}
+	if subcommand.Name == "buy" {
+		buyer, err := getOwnerOrDefault(options, "buyer", payer, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		seller, err := getOwnerOrDefault(options, "seller", defaultOwner, st)
+		if err != nil {
+			say(err.Error(), s, m)
+			return
+		}
+		if !a.canSpend(buyer) {
+			say(DeniedMessage+" You can only buy for your own inventory.", s, m)
+			return
+		}
+		b.reply(b.buyItem(
+			count,
+			getStringOrDefault(options, "item", ""),
+			buyer,
+			seller,
+		), buyer, s, m)
+		return
+	}
 
 	// Handle add, remove, and set.
-	price, err := getIntOrDefault(options, "price", Unchanged)
+	if !a.canEdit() {
+		say(DeniedMessage+" Only gamemasters can "+subcommand.Name+" items.", s, m)
+		return
+	}
+	owner, err := getOwnerOrDefault(options, "owner", defaultOwner, st)
 	if err != nil {
-		say("Invalid price. Please use a whole number.", s, m)
+		say(err.Error(), s, m)
 		return
 	}
-	say(b.modifyItem(
+
+	if subcommand.Name == "bulk" {
+		op := getStringOrDefault(options, "action", "add")
+		if _, ok := options["items"]; ok {
+			b.reply(b.bulkModify(
+				getStringOrDefault(options, "items", ""),
+				owner,
+				op,
+			), owner, s, m)
+			return
+		}
+		modal := bulkModal(op, owner)
+		if len(modal.CustomID) > customIDLimit {
+			say("That owner's name is too long for a form. "+
+				"Please list the items separated by semicolons.", s, m)
+			return
+		}
+		s.InteractionRespond(m.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: modal,
+		})
+		return
+	}
+
+	if subcommand.Name == "offer" {
+		var reply []string
+		if _, ok := options["item"]; ok {
+			offer, err := getPriceOrDefault(options, "price", 0, b.currency)
+			if err != nil {
+				say("Invalid price. "+priceHelp(b.currency), s, m)
+				return
+			}
+			reply = append(reply, b.setOffer(
+				getStringOrDefault(options, "item", ""),
+				offer,
+				owner,
+			))
+		}
+		if _, ok := options["budget"]; ok {
+			budget, err := getPriceOrDefault(options, "budget", 0, b.currency)
+			if err != nil {
+				say("Invalid budget. "+priceHelp(b.currency), s, m)
+				return
+			}
+			// Budgets are spent in whole coins.
+			coins := -1
+			if budget >= 0 {
+				var ok bool
+				coins, ok = budget.ceil()
+				if !ok {
+					say(OverflowMessage, s, m)
+					return
+				}
+			}
+			reply = append(reply, b.setBudget(coins, owner))
+		}
+		if len(reply) == 0 {
+			say("You forgot to request an item or budget.", s, m)
+			return
+		}
+		say(strings.Join(reply, "\n"), s, m)
+		return
+	}
+
+	price, err := getPriceOrDefault(options, "price", Unchanged, b.currency)
+	if err != nil {
+		say("Invalid price. "+priceHelp(b.currency), s, m)
+		return
+	}
+	b.reply(b.modifyItem(
 		count,
 		price,
-		getStringOrDefault(options, "item", Coin),
-		getStringOrDefault(options, "owner", defaultOwner),
+		getStringOrDefault(options, "item", b.currency.base()),
+		owner,
 		subcommand.Name,
-	), s, m)
+	), owner, s, m)
+}
+
+// prepare returns a backpack for the guild and user of an interaction along
+// with the guild's settings and the user's access. The returned error is a
+// message for the user.
+func (b backpack) prepare(m *discordgo.InteractionCreate) (backpack, settings, access, error) {
+	var st settings
+	var a access
+	if m.GuildID == "" {
+		return b, st, a, errors.New("Backpack only works in servers.")
+	}
+	b, err := b.inGuild(m.GuildID)
+	if err != nil {
+		log.Println(err)
+		return b, st, a, errors.New(FatalMessage)
+	}
+	b.interaction = m.ID
+	if m.Member != nil && m.Member.User != nil {
+		b.actor = m.Member.User.ID
+	}
+
+	st, err = b.loadSettings()
+	if err != nil {
+		log.Printf("error loading settings: %v\n", err)
+		return b, st, a, errors.New(FatalMessage)
+	}
+	b.currency = st.Currency
+	b.render = st.Render
+	b.catalog, err = b.loadCatalog()
+	if err != nil {
+		log.Printf("error loading catalog: %v\n", err)
+		return b, st, a, errors.New(FatalMessage)
+	}
+	a = memberAccess(m.Member, st)
+	return b, st, a, nil
 }
 
 // say something in the chat.
@@ -257,6 +1155,18 @@ func say(msg string, s *discordgo.Session, m *discordgo.InteractionCreate) {
 	})
 }
 
+// whisper something in the chat which only the user who sent the interaction
+// can see.
+func whisper(msg string, s *discordgo.Session, m *discordgo.InteractionCreate) {
+	s.InteractionRespond(m.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: msg,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
 // getStringOrDefault will return the option or a default string.
 func getStringOrDefault(
 	options map[string]*discordgo.ApplicationCommandInteractionDataOption,
@@ -269,6 +1179,18 @@ func getStringOrDefault(
 	return defaultValue
 }
 
+// getOwnerOrDefault will return the inventory key of the owner option or a
+// default owner. An error explaining which owners may be used is returned if
+// the option is not a valid owner.
+func getOwnerOrDefault(
+	options map[string]*discordgo.ApplicationCommandInteractionDataOption,
+	key string,
+	defaultValue string,
+	st settings,
+) (string, error) {
+	return resolveOwner(getStringOrDefault(options, key, defaultValue), st)
+}
+
 // getIntOrDefault will return the option or a default int.
 func getIntOrDefault(
 	options map[string]*discordgo.ApplicationCommandInteractionDataOption,
@@ -282,6 +1204,32 @@ func getIntOrDefault(
 	return defaultValue, nil
 }
 
+// getPriceOrDefault gets a price written in currency c from the options or
+// returns the default value. Negative prices mean the item is not for sale.
+func getPriceOrDefault(
+	options map[string]*discordgo.ApplicationCommandInteractionDataOption,
+	key string,
+	defaultValue money,
+	c currency,
+) (money, error) {
+	if opt, ok := options[key]; ok {
+		price, err := c.parse(opt.StringValue())
+		if price < 0 {
+			price = NotForSale
+		}
+		return price, err
+	}
+	return defaultValue, nil
+}
+
+// priceHelp explains how to write a price in currency c.
+func priceHelp(c currency) string {
+	if len(c) == 0 {
+		return "Please use a number such as \"1.50\"."
+	}
+	return fmt.Sprintf("Please use coins such as \"%v\".", c.formatCoins(c.denominations()[0].Value+1))
+}
+
 // mapOptions takes a list of options and makes a map of them based on their name.
 func mapOptions(
 	options []*discordgo.ApplicationCommandInteractionDataOption,
@@ -0,0 +1,107 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// tradeButtons returns the accept and decline buttons for the trade offer
+// stored under id.
+func tradeButtons(id string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Accept",
+					Style:    discordgo.SuccessButton,
+					CustomID: "trade:accept:" + id,
+				},
+				discordgo.Button{
+					Label:    "Decline",
+					Style:    discordgo.DangerButton,
+					CustomID: "trade:decline:" + id,
+				},
+			},
+		},
+	}
+}
+
+// componentHandler is called when a button on one of backpack's messages is
+// pressed. Button IDs are made of a kind, an action, and an ID separated by
+// colons. For views the action is the page to show and the ID is the view's
+// query.
+func (b backpack) componentHandler(s *discordgo.Session, m *discordgo.InteractionCreate) {
+	parts := strings.SplitN(m.MessageComponentData().CustomID, ":", 3)
+	if len(parts) != 3 {
+		return
+	}
+	kind, action, id := parts[0], parts[1], parts[2]
+
+	b, _, a, err := b.prepare(m)
+	if err != nil {
+		whisper(err.Error(), s, m)
+		return
+	}
+
+	switch kind {
+	case "view":
+		if !a.canView() {
+			whisper(DeniedMessage, s, m)
+			return
+		}
+		q, err := decodeViewQuery(id)
+		if err != nil {
+			log.Println(err)
+			whisper(FatalMessage, s, m)
+			return
+		}
+		page, err := strconv.Atoi(action)
+		if err != nil {
+			whisper(FatalMessage, s, m)
+			return
+		}
+		showPage(b.viewPage(q, page, stateLabel(s, m.GuildID)), s, m)
+	case "trade":
+		t, err := b.loadTrade(id)
+		if err == errNoTrade {
+			update("This trade offer has expired.", s, m)
+			return
+		} else if err != nil {
+			whisper(FatalMessage, s, m)
+			return
+		}
+
+		switch action {
+		case "accept":
+			if !a.canAccept(t) {
+				whisper(DeniedMessage+" Only "+t.To+" can accept.", s, m)
+				return
+			}
+			update(b.acceptTrade(id), s, m)
+		case "decline":
+			// The proposer may also withdraw their offer.
+			if !a.canAccept(t) && b.actor != t.Proposer {
+				whisper(DeniedMessage+" Only "+t.To+" can decline.", s, m)
+				return
+			}
+			update(b.declineTrade(id), s, m)
+		}
+	}
+}
+
+// update replaces the message a pressed button belongs to, removing all of its
+// buttons.
+func update(msg string, s *discordgo.Session, m *discordgo.InteractionCreate) {
+	s.InteractionRespond(m.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    msg,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
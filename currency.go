@@ -0,0 +1,410 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// denomination is a single kind of coin.
+type denomination struct {
+	// Name is the item name of the coin, such as "gold piece".
+	Name string `json:"name"`
+
+	// Symbol is used when writing prices, such as "gp" in "2gp 5sp".
+	Symbol string `json:"symbol"`
+
+	// Value is the worth of the coin in the smallest denomination.
+	Value int `json:"value"`
+}
+
+// currency is the money used in a guild. Without any denominations a single
+// coin is used and prices are written like "$10".
+type currency []denomination
+
+// denominations returns the currency's coins from most to least valuable.
+func (c currency) denominations() []denomination {
+	if len(c) == 0 {
+		return []denomination{{Name: Coin, Symbol: "$", Value: 1}}
+	}
+	ds := append([]denomination(nil), c...)
+	sort.SliceStable(ds, func(i, j int) bool {
+		return ds[i].Value > ds[j].Value
+	})
+	return ds
+}
+
+// base returns the name of the least valuable coin.
+func (c currency) base() string {
+	ds := c.denominations()
+	return ds[len(ds)-1].Name
+}
+
+// value returns the worth of a coin with the given item name. ok is false if
+// the item is not money.
+func (c currency) value(name string) (value int, ok bool) {
+	for _, d := range c.denominations() {
+		if d.Name == name {
+			return d.Value, true
+		}
+	}
+	return 0, false
+}
+
+// isMoney reports whether the item is one of the currency's coins.
+func (c currency) isMoney(name string) bool {
+	_, ok := c.value(name)
+	return ok
+}
+
+// format writes an amount of money such as "2gp 5sp" or "$10". Fractions of
+// a coin are written with the least valuable coin such as "1sp 2.50cp".
+func (c currency) format(m money) string {
+	whole, frac := int64(m/coin), int64(m%coin)
+	if frac < 0 {
+		frac = -frac
+	}
+	if len(c) == 0 {
+		s := "$" + humanize.Comma(whole)
+		if frac != 0 {
+			s += fmt.Sprintf(".%02d", frac)
+		}
+		return s
+	}
+
+	var parts []string
+	ds := c.denominations()
+	for i, d := range ds {
+		n := whole / int64(d.Value)
+		whole %= int64(d.Value)
+		if i == len(ds)-1 && frac != 0 {
+			parts = append(parts, fmt.Sprintf(
+				"%v.%02d%v",
+				humanize.Comma(n),
+				frac,
+				d.Symbol,
+			))
+		} else if n != 0 {
+			parts = append(parts, humanize.Comma(n)+d.Symbol)
+		}
+	}
+	if len(parts) == 0 {
+		return "0" + ds[len(ds)-1].Symbol
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatCoins writes a number of the least valuable coin like format.
+func (c currency) formatCoins(n int) string {
+	m, ok := coins(int64(n))
+	if !ok {
+		return humanize.Comma(int64(n)) + " " + displayName(c.base(), n)
+	}
+	return c.format(m)
+}
+
+// symbolPattern matches the symbols which may be used for coins.
+var symbolPattern = regexp.MustCompile(`^[^0-9\s,$-]{1,8}$`)
+
+// errDeclined stops updateSettings without it being a failure.
+var errDeclined = errors.New("declined")
+
+// errUnbased stops updateSettings from saving a currency whose least valuable
+// coin isn't worth 1.
+var errUnbased = errors.New("no coin worth 1")
+
+// pricePattern matches a single part of a price such as "2gp" or "5.5 sp".
+var pricePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([^0-9\s.]*)`)
+
+// parse reads an amount of money written like format does. A number without a
+// symbol is an amount of the least valuable coin.
+func (c currency) parse(s string) (money, error) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, ",", ""))
+	if len(c) == 0 {
+		return parseMoney(strings.TrimPrefix(s, "$"))
+	}
+	if strings.HasPrefix(s, "-") {
+		amount, err := c.parse(s[1:])
+		return -amount, err
+	}
+	if s == "" {
+		return 0, fmt.Errorf("empty price")
+	}
+
+	ds := c.denominations()
+	var amount money
+	for s != "" {
+		m := pricePattern.FindStringSubmatch(s)
+		if m == nil {
+			return 0, fmt.Errorf("invalid price: %v", s)
+		}
+		n, err := parseMoney(m[1])
+		if err != nil {
+			return 0, err
+		}
+		value := ds[len(ds)-1].Value
+		if m[2] != "" {
+			value = 0
+			for _, d := range c {
+				if strings.EqualFold(d.Symbol, m[2]) {
+					value = d.Value
+				}
+			}
+			if value == 0 {
+				return 0, fmt.Errorf("unknown coin: %v", m[2])
+			}
+		}
+		n, ok := n.times(value)
+		if !ok {
+			return 0, fmt.Errorf("price too large: %v", s)
+		}
+		sum := amount + n
+		if sum < amount {
+			return 0, fmt.Errorf("price too large: %v", s)
+		}
+		amount = sum
+		s = strings.TrimSpace(s[len(m[0]):])
+	}
+	return amount, nil
+}
+
+// wealth returns the worth of all the coins in recs in the least valuable
+// coin. Wealth too large to count is capped at the largest int.
+func (c currency) wealth(recs records) int {
+	var sum int
+	for _, r := range recs {
+		v, ok := c.value(r.name)
+		if !ok {
+			continue
+		}
+		worth, ok := mulCounts(r.count, v)
+		if !ok {
+			return math.MaxInt
+		}
+		sum, ok = addCounts(sum, worth)
+		if !ok {
+			return math.MaxInt
+		}
+	}
+	return sum
+}
+
+// balance describes the coins in recs. Without any denominations it is simply
+// the number of coins.
+func (c currency) balance(recs records) string {
+	if len(c) == 0 {
+		return record{count: c.wealth(recs), name: Coin, price: Unchanged}.String()
+	}
+	return c.formatCoins(c.wealth(recs))
+}
+
+// valid returns an error if amounts can't always be made with the currency's
+// coins, which needs the least valuable coin to be worth 1.
+func (c currency) valid() error {
+	ds := c.denominations()
+	if base := ds[len(ds)-1]; base.Value != 1 {
+		return fmt.Errorf(
+			"least valuable coin %v is worth %v not 1",
+			base.Name,
+			base.Value,
+		)
+	}
+	return nil
+}
+
+// change breaks an amount into as few coins as possible. The count of each
+// denomination is returned, most valuable first.
+func (c currency) change(amount int) []int {
+	ds := c.denominations()
+	counts := make([]int, len(ds))
+	for i, d := range ds {
+		counts[i] = amount / d.Value
+		amount %= d.Value
+	}
+	return counts
+}
+
+// pay stages a payment of amount from one inventory to another. The payer
+// uses whichever of their coins add up to the amount, most valuable first,
+// and receives change if no coins add up exactly. The payee receives the
+// amount in as few coins as possible. A declinedError is returned if the payer
+// can't afford it. An error is returned if the coins moved would not add up to
+// exactly the amount.
+func (tx *transaction) pay(c currency, from, to string, amount int) error {
+	if amount == 0 {
+		return nil
+	}
+	if amount < 0 {
+		return fmt.Errorf("failed paying %v: amount is negative", amount)
+	}
+	if err := c.valid(); err != nil {
+		return fmt.Errorf("failed paying %v: %v", amount, err)
+	}
+	recs, err := tx.loadRecords(from)
+	if err != nil {
+		return err
+	}
+	if c.wealth(recs) < amount {
+		return &declinedError{record{
+			count: -amount,
+			name:  c.base(),
+			price: NotForSale,
+		}}
+	}
+
+	ds := c.denominations()
+	have := make([]int, len(ds))
+	for i, d := range ds {
+		for _, r := range recs {
+			if r.name == d.Name {
+				have[i] += r.count
+			}
+		}
+	}
+
+	// Pay with the most valuable coins which fit, then overpay with the
+	// least valuable coin left and take change.
+	deltas := make([]int, len(ds))
+	remaining := amount
+	for i, d := range ds {
+		use := remaining / d.Value
+		if use > have[i] {
+			use = have[i]
+		}
+		deltas[i] -= use
+		remaining -= use * d.Value
+	}
+	if remaining > 0 {
+		for i := len(ds) - 1; i >= 0; i-- {
+			if have[i]+deltas[i] > 0 && ds[i].Value > remaining {
+				deltas[i]--
+				for j, n := range c.change(ds[i].Value - remaining) {
+					deltas[j] += n
+				}
+				break
+			}
+		}
+	}
+
+	received := c.change(amount)
+	var paid, sum int
+	for i, d := range ds {
+		paid -= deltas[i] * d.Value
+		sum += received[i] * d.Value
+	}
+	if paid != amount || sum != amount {
+		return fmt.Errorf(
+			"failed paying %v: paid %v and received %v",
+			amount,
+			paid,
+			sum,
+		)
+	}
+
+	for i, d := range ds {
+		if deltas[i] == 0 {
+			continue
+		}
+		_, _, err := tx.updateRecord(record{
+			count: deltas[i],
+			name:  d.Name,
+			price: NotForSale,
+		}, from, false)
+		if err != nil {
+			return err
+		}
+	}
+	for i, n := range received {
+		if n == 0 {
+			continue
+		}
+		_, _, err := tx.updateRecord(record{
+			count: n,
+			name:  ds[i].Name,
+			price: NotForSale,
+		}, to, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setDenomination adds a coin to the guild's currency or changes an existing
+// one. A value of 0 removes the coin.
+func (b backpack) setDenomination(name, symbol string, value int) string {
+	log.Println("currency", name, symbol, value)
+	name = normalizeName(name)
+	if name == "" {
+		return "You forgot to name the coin."
+	}
+	if value < 0 {
+		return "Invalid value. Please use a positive whole number."
+	}
+	if value > 0 && !symbolPattern.MatchString(symbol) {
+		return "Invalid symbol. Please use letters such as \"gp\"."
+	}
+
+	var c currency
+	var taken string
+	err := b.updateSettings(func(st *settings) error {
+		var ds currency
+		for _, d := range st.Currency {
+			if d.Name == name {
+				continue
+			}
+			if value > 0 && strings.EqualFold(d.Symbol, symbol) {
+				taken = d.Name
+				return errDeclined
+			}
+			ds = append(ds, d)
+		}
+		if value > 0 {
+			ds = append(ds, denomination{
+				Name:   name,
+				Symbol: symbol,
+				Value:  value,
+			})
+		}
+		if len(ds) > 0 && ds.valid() != nil {
+			return errUnbased
+		}
+		st.Currency = ds
+		c = ds
+		return nil
+	})
+	if err == errDeclined {
+		return fmt.Sprintf("%v already uses %v", displayName(taken, 2), symbol)
+	} else if err == errUnbased {
+		return "The least valuable coin must have a value of 1. " +
+			"Please add it before any others."
+	} else if err != nil {
+		log.Printf("error setting denomination %v: %v\n", name, err)
+		return FatalMessage
+	}
+
+	if len(c) == 0 {
+		return "The currency is now plain coins."
+	}
+	ds := c.denominations()
+	base := ds[len(ds)-1]
+	var coins []string
+	for _, d := range ds {
+		coins = append(coins, fmt.Sprintf(
+			"%v (%v) worth %v%v",
+			displayName(d.Name, 1),
+			d.Symbol,
+			humanize.Comma(int64(d.Value)),
+			base.Symbol,
+		))
+	}
+	return "The currency is now: " + strings.Join(coins, ", ")
+}
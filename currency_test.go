@@ -0,0 +1,306 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testCurrency = currency{
+	{Name: "silver piece", Symbol: "sp", Value: 10},
+	{Name: "gold piece", Symbol: "gp", Value: 100},
+	{Name: "copper piece", Symbol: "cp", Value: 1},
+}
+
+func TestCurrencyFormat(t *testing.T) {
+	type test struct {
+		c      currency
+		amount money
+		want   string
+	}
+
+	tests := []test{
+		{nil, 150000, "$1,500"},
+		{nil, 150, "$1.50"},
+		{testCurrency, 25000, "2gp 5sp"},
+		{testCurrency, 120300, "12gp 3cp"},
+		{testCurrency, 1050, "1sp 0.50cp"},
+		{testCurrency, 0, "0cp"},
+	}
+
+	for _, tc := range tests {
+		got := tc.c.format(tc.amount)
+		if got != tc.want {
+			t.Fatalf("format(%v): want %q got %q", tc.amount, tc.want, got)
+		}
+	}
+}
+
+func TestCurrencyParse(t *testing.T) {
+	type test struct {
+		c       currency
+		s       string
+		want    money
+		wantErr bool
+	}
+
+	tests := []test{
+		{nil, "15", 1500, false},
+		{nil, "$15", 1500, false},
+		{nil, "1.5", 150, false},
+		{nil, "0.125", 0, true},
+		{nil, "2gp", 0, true},
+		{testCurrency, "2gp 5sp", 25000, false},
+		{testCurrency, "2 GP, 5 sp", 25000, false},
+		{testCurrency, "1.5gp", 15000, false},
+		{testCurrency, "7", 700, false},
+		{testCurrency, "-1", -100, false},
+		{testCurrency, "99999999999999999999gp", 0, true},
+		{testCurrency, "3pp", 0, true},
+		{testCurrency, "", 0, true},
+	}
+
+	for _, tc := range tests {
+		got, err := tc.c.parse(tc.s)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("parse(%q): expected an error", tc.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parse(%q): %v", tc.s, err)
+		}
+		if got != tc.want {
+			t.Fatalf("parse(%q): want %v got %v", tc.s, tc.want, got)
+		}
+	}
+}
+
+func TestBuyItemWithCurrency(t *testing.T) {
+	type test struct {
+		count  int
+		item   string
+		buyer  string
+		seller string
+
+		wantReply  string
+		buyerWant  string
+		sellerWant string
+	}
+
+	tests := []test{
+		{
+			count:  3,
+			item:   "apples",
+			buyer:  "1,gold piece,-1",
+			seller: "10,apple,25",
+			wantReply: "buyer bought 3 Apples for 7sp 5cp\n" +
+				"buyer has 3 Apples\n" +
+				"seller has 7 Apples for sale for 2sp 5cp",
+			buyerWant: "0,gold piece,-1\n" +
+				"2,silver piece,-1\n" +
+				"5,copper piece,-1\n" +
+				"3,apple,-1",
+			sellerWant: "7,apple,25\n" +
+				"7,silver piece,-1\n" +
+				"5,copper piece,-1",
+		},
+		{
+			count:  1,
+			item:   "apples",
+			buyer:  "2,silver piece,-1\n9,copper piece,-1",
+			seller: "10,apple,25",
+			wantReply: "buyer bought 1 Apple for 2sp 5cp\n" +
+				"buyer has 1 Apple\n" +
+				"seller has 9 Apples for sale for 2sp 5cp",
+			buyerWant: "0,silver piece,-1\n" +
+				"4,copper piece,-1\n" +
+				"1,apple,-1",
+			sellerWant: "9,apple,25\n" +
+				"2,silver piece,-1\n" +
+				"5,copper piece,-1",
+		},
+		{
+			count:  1,
+			item:   "apples",
+			buyer:  "5,copper piece,-1",
+			seller: "10,apple,25",
+			wantReply: "buyer has insufficient funds\n" +
+				"1 Apple costs 2sp 5cp\n" +
+				"buyer only has 5cp",
+			buyerWant:  "5,copper piece,-1",
+			sellerWant: "10,apple,25",
+		},
+		{
+			count:      1,
+			item:       "gold pieces",
+			buyer:      "",
+			seller:     "1,gold piece,-1",
+			wantReply:  "You can't buy coins silly!",
+			buyerWant:  "",
+			sellerWant: "1,gold piece,-1",
+		},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		buyerPath := filepath.Join(dir, "buyer.csv")
+		err := os.WriteFile(buyerPath, []byte(tc.buyer), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sellerPath := filepath.Join(dir, "seller.csv")
+		err = os.WriteFile(sellerPath, []byte(tc.seller), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := backpack{
+			dir:      dir,
+			currency: testCurrency,
+		}
+		reply := b.buyItem(tc.count, tc.item, "buyer", "seller")
+		if tc.wantReply != reply {
+			t.Fatalf(
+				"incorrect reply:\nwant:\n%v\ngot:\n%v\n",
+				tc.wantReply,
+				reply,
+			)
+		}
+
+		buyerGot, err := os.ReadFile(buyerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(buyerGot)) != tc.buyerWant {
+			t.Fatalf(
+				"incorrect buyer inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.buyerWant,
+				string(buyerGot),
+			)
+		}
+		sellerGot, err := os.ReadFile(sellerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(sellerGot)) != tc.sellerWant {
+			t.Fatalf(
+				"incorrect seller inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.sellerWant,
+				string(sellerGot),
+			)
+		}
+	}
+}
+
+func TestRecordsTableWithCurrency(t *testing.T) {
+	recs := records{
+		{count: 2, name: "gold piece", price: NotForSale},
+		{count: 3, name: "apple", price: 2500},
+		{count: 5, name: "copper piece", price: NotForSale},
+	}
+	want := "```\n" +
+		"╔══════════════════════════════════╗\n" +
+		"║ Quantity  Item           Price   ║\n" +
+		"║──────────────────────────────────║\n" +
+		"║ 2         Gold pieces            ║\n" +
+		"║ 3         Apples         2sp 5cp ║\n" +
+		"║ 5         Copper pieces          ║\n" +
+		"║──────────────────────────────────║\n" +
+		"║ Total: 2gp 5cp                   ║\n" +
+		"╚══════════════════════════════════╝\n" +
+		"```"
+	got := recs.table(view{currency: testCurrency})
+	if got != want {
+		t.Fatalf("incorrect table:\nwant:\n%v\ngot:\n%v\n", want, got)
+	}
+}
+
+func TestBuyItemUnbasedCurrency(t *testing.T) {
+	dir := t.TempDir()
+	buyerPath := filepath.Join(dir, "buyer.csv")
+	err := os.WriteFile(buyerPath, []byte("1,gold piece,-1"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sellerPath := filepath.Join(dir, "seller.csv")
+	err = os.WriteFile(sellerPath, []byte("10,apple,3"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := backpack{
+		dir: dir,
+		currency: currency{
+			{Name: "gold piece", Symbol: "gp", Value: 10},
+			{Name: "silver piece", Symbol: "sp", Value: 4},
+		},
+	}
+	if reply := b.buyItem(1, "apple", "buyer", "seller"); reply != FatalMessage {
+		t.Fatalf("bought with coins which can't pay exactly: %v", reply)
+	}
+	buyerGot, err := os.ReadFile(buyerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buyerGot) != "1,gold piece,-1" {
+		t.Fatalf("buyer inventory changed:\n%v", string(buyerGot))
+	}
+	sellerGot, err := os.ReadFile(sellerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sellerGot) != "10,apple,3" {
+		t.Fatalf("seller inventory changed:\n%v", string(sellerGot))
+	}
+}
+
+func TestSetDenomination(t *testing.T) {
+	type test struct {
+		name   string
+		symbol string
+		value  int
+
+		want string
+	}
+
+	unbased := "The least valuable coin must have a value of 1. " +
+		"Please add it before any others."
+	tests := []test{
+		{"gold piece", "gp", 10, unbased},
+		{"copper piece", "cp", 1, "The currency is now: Copper piece (cp) worth 1cp"},
+		{"silver piece", "sp", 4, "The currency is now: " +
+			"Silver piece (sp) worth 4cp, " +
+			"Copper piece (cp) worth 1cp"},
+		{"copper piece", "cp", 0, unbased},
+		{"copper piece", "cp", 2, unbased},
+		{"silver piece", "sp", 0, "The currency is now: Copper piece (cp) worth 1cp"},
+		{"copper piece", "cp", 0, "The currency is now plain coins."},
+	}
+
+	b := backpack{dir: t.TempDir()}
+	for _, tc := range tests {
+		got := b.setDenomination(tc.name, tc.symbol, tc.value)
+		if got != tc.want {
+			t.Fatalf(
+				"setDenomination(%v, %v): want:\n%v\ngot:\n%v",
+				tc.name, tc.value,
+				tc.want,
+				got,
+			)
+		}
+	}
+}
+
+func TestPayNegative(t *testing.T) {
+	b := backpack{dir: t.TempDir()}
+	tx := b.begin("buy", "buyer", "seller")
+	defer tx.release()
+	if err := tx.pay(nil, "buyer", "seller", -5); err == nil {
+		t.Fatal("paid a negative amount")
+	}
+}
@@ -3,30 +3,108 @@
 package main
 
 import (
-	"bytes"
-	"encoding/csv"
-	"errors"
 	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-	"strconv"
+	"sort"
+	"sync"
+	"time"
 )
 
-// updateRecord updates a record with v in a csv file located at dir/owner.csv.
-//
-// absolute indicates that we should set the count instead of adding to the
-// existing count.
-//
-// The updated record and the old record, or an error are returned.
-func updateRecord(v record, dir, owner string, absolute bool) (record, record, error) {
+// transaction stages changes to the inventories of several owners which are
+// then written all at once by commit. If a transaction is abandoned before
+// commit is called nothing is written.
+type transaction struct {
+	store   storage
+	owners  map[string]bool
+	loaded  map[string]records
+	changed map[string]bool
+	unlock  func()
+
+	// op, actor, and interaction describe the command making the changes
+	// in the ledger.
+	op          string
+	actor       string
+	interaction string
+	entries     []ledgerEntry
+
+	// ref is recorded as the interaction reverted by the following changes
+	// when undoing.
+	ref string
+}
+
+// newTransaction returns an empty transaction for the inventories of owners in
+// s. The inventories are locked until release is called.
+func newTransaction(s storage, owners ...string) *transaction {
+	tx := &transaction{
+		store:   s,
+		owners:  make(map[string]bool, len(owners)),
+		loaded:  make(map[string]records),
+		changed: make(map[string]bool),
+	}
+	keys := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		tx.owners[owner] = true
+		keys = append(keys, s.String()+"\x00"+owner)
+	}
+	tx.unlock = inventoryLocks.lock(keys...)
+	return tx
+}
+
+// dataKey returns the lock key of data in s other than an inventory, such as
+// the catalog. Owner names never contain a slash so it can't lock an
+// inventory.
+func dataKey(s storage, name string) string {
+	return s.String() + "\x00/" + name
+}
+
+// begin returns a transaction for the inventories of owners which records its
+// changes in the ledger as op done by the user running the current command.
+func (b backpack) begin(op string, owners ...string) *transaction {
+	tx := newTransaction(b.storage(), owners...)
+	tx.op = op
+	tx.actor = b.actor
+	tx.interaction = b.interaction
+	return tx
+}
+
+// release unlocks the inventories held by the transaction. Any uncommitted
+// changes are discarded.
+func (tx *transaction) release() {
+	if tx.unlock != nil {
+		tx.unlock()
+		tx.unlock = nil
+	}
+	tx.loaded = make(map[string]records)
+	tx.changed = make(map[string]bool)
+	tx.entries = nil
+}
+
+// loadRecords returns the records of owner as seen by the transaction.
+func (tx *transaction) loadRecords(owner string) (records, error) {
+	if !tx.owners[owner] {
+		return nil, fmt.Errorf("inventory %v is not locked by transaction", owner)
+	}
+	if recs, ok := tx.loaded[owner]; ok {
+		return recs, nil
+	}
+	recs, err := tx.store.loadRecords(owner)
+	if err != nil {
+		return recs, err
+	}
+	tx.loaded[owner] = recs
+	return recs, nil
+}
+
+// updateRecord stages an update of a record with v in owner's inventory. It
+// works like the updateRecord function, but nothing is written until commit.
+func (tx *transaction) updateRecord(v record, owner string, absolute bool) (record, record, error) {
 	var updated record
 	var old record
-	path := filepath.Join(dir, owner+".csv")
-	recs, err := loadRecords(path)
+	recs, err := tx.loadRecords(owner)
 	if err != nil {
 		return updated, old, err
 	}
+	// Work on a copy so a declined update leaves the staged records alone.
+	recs = append(records(nil), recs...)
 
 	var found bool
 	for i := range recs {
@@ -63,71 +141,124 @@ func updateRecord(v record, dir, owner string, absolute bool) (record, record, e
 		recs = append(recs, v)
 	}
 
-	return updated, old, storeRecords(path, recs)
+	tx.loaded[owner] = recs
+	tx.changed[owner] = true
+	var oldPrice money = NotForSale
+	if found {
+		oldPrice = old.price
+	}
+	tx.entries = append(tx.entries, ledgerEntry{
+		interaction: tx.interaction,
+		actor:       tx.actor,
+		op:          tx.op,
+		owner:       owner,
+		item:        updated.name,
+		delta:       updated.count - old.count,
+		price:       updated.price,
+		oldPrice:    oldPrice,
+		ref:         tx.ref,
+	})
+	return updated, old, nil
 }
 
-// loadRecords reads a csv file located at path and parses the contents into a
-// list of records.
-func loadRecords(path string) (records, error) {
-	var recs records
-
-	d, err := os.ReadFile(path)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return recs, fmt.Errorf("failed reading %v: %v", path, err)
+// setOffer stages a change of the price owner pays to buy an item back. The
+// item is added with no stock if owner does not have it. The updated record is
+// returned.
+func (tx *transaction) setOffer(name string, offer money, owner string) (record, error) {
+	var updated record
+	recs, err := tx.loadRecords(owner)
+	if err != nil {
+		return updated, err
 	}
-	r := csv.NewReader(bytes.NewReader(d))
+	recs = append(records(nil), recs...)
 
-	for {
-		line, err := r.Read()
-		if err == io.EOF {
+	old := record{name: name, price: NotForSale}
+	i := 0
+	for ; i < len(recs); i++ {
+		if recs[i].name == name {
+			old = recs[i]
 			break
 		}
-		if err != nil {
-			return recs, fmt.Errorf("failed parsing %v: %v", path, err)
-		}
-		count, err := strconv.Atoi(line[0])
-		if err != nil {
-			return recs, fmt.Errorf(
-				"failed parsing count as int: %v",
-				line[0],
-			)
-		}
-		price, err := strconv.Atoi(line[2])
-		if err != nil {
-			return recs, fmt.Errorf(
-				"failed parsing price as int: %v",
-				line[0],
-			)
-		}
-		rec := record{
-			count: count,
-			name:  line[1],
-			price: price,
-		}
-		recs = append(recs, rec)
 	}
+	if i == len(recs) {
+		recs = append(recs, old)
+	}
+	recs[i].offer = offer
+	updated = recs[i]
 
-	return recs, nil
+	tx.loaded[owner] = recs
+	tx.changed[owner] = true
+	tx.entries = append(tx.entries, ledgerEntry{
+		interaction: tx.interaction,
+		actor:       tx.actor,
+		op:          tx.op,
+		owner:       owner,
+		item:        name,
+		price:       offer,
+		oldPrice:    old.offer,
+		ref:         tx.ref,
+	})
+	return updated, nil
 }
 
-// storeRecords writes a list of records to a csv file at path.
-func storeRecords(path string, records records) error {
-	var lines [][]string
-	for _, r := range records {
-		count := strconv.Itoa(r.count)
-		price := strconv.Itoa(r.price)
-		line := []string{
-			count,
-			r.name,
-			price,
-		}
-		lines = append(lines, line)
+// commit writes every changed inventory and appends the changes to the ledger
+// all at once.
+func (tx *transaction) commit() error {
+	if len(tx.changed) == 0 {
+		return nil
+	}
+	changes := make(map[string]records, len(tx.changed))
+	for owner := range tx.changed {
+		changes[owner] = tx.loaded[owner]
+	}
+	entries := tx.entries
+	tx.changed = make(map[string]bool)
+	tx.entries = nil
+
+	now := time.Now().UTC()
+	for i := range entries {
+		entries[i].time = now
 	}
+	return tx.store.storeRecords(changes, entries)
+}
+
+// inventoryLocks guards every inventory file against concurrent
+// read-modify-write cycles.
+var inventoryLocks = lockManager{locks: make(map[string]*sync.Mutex)}
+
+// lockManager hands out a mutex per key.
+type lockManager struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock acquires the mutex of every key and returns a function which releases
+// them. Keys are always locked in sorted order so that two callers locking an
+// overlapping set of keys cannot deadlock.
+func (l *lockManager) lock(keys ...string) func() {
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+
+	var held []*sync.Mutex
+	for i, key := range keys {
+		if i > 0 && keys[i-1] == key {
+			continue
+		}
+		l.mu.Lock()
+		m, ok := l.locks[key]
+		if !ok {
+			m = new(sync.Mutex)
+			l.locks[key] = m
+		}
+		l.mu.Unlock()
 
-	var buf bytes.Buffer
-	w := csv.NewWriter(&buf)
+		m.Lock()
+		held = append(held, m)
+	}
 
-	w.WriteAll(lines)
-	w.Flush()
-	return os.WriteFile(path, buf.Bytes(), 0600)
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].Unlock()
+		}
+	}
 }
@@ -0,0 +1,269 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// updateRecord updates a record with v in owner's inventory in a transaction
+// of its own.
+//
+// absolute indicates that we should set the count instead of adding to the
+// existing count.
+//
+// The updated record and the old record, or an error are returned.
+func updateRecord(v record, s storage, owner string, absolute bool) (record, record, error) {
+	tx := newTransaction(s, owner)
+	defer tx.release()
+	updated, old, err := tx.updateRecord(v, owner, absolute)
+	if err != nil {
+		return updated, old, err
+	}
+	return updated, old, tx.commit()
+}
+
+func TestTransactionCommit(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "a.csv"), []byte("5,apple,-1"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := newTransaction(csvStorage{dir: dir}, "a", "b")
+	defer tx.release()
+	if _, _, err := tx.updateRecord(record{count: -2, name: "apple", price: Unchanged}, "a", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tx.updateRecord(record{count: 2, name: "apple", price: Unchanged}, "b", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing may be written before commit.
+	if _, err := os.Stat(filepath.Join(dir, "b.csv")); !os.IsNotExist(err) {
+		t.Fatalf("b.csv written before commit: %v", err)
+	}
+	if err := tx.commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"a.csv": "3,apple,-1",
+		"b.csv": "2,apple,-1",
+	}
+	for name, w := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(got)) != w {
+			t.Fatalf("%v want: %v got: %v\n", name, w, string(got))
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("leftover files after commit: %v", entries)
+	}
+}
+
+func TestTransactionDeclined(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.csv")
+	err := os.WriteFile(path, []byte("5,apple,-1"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := newTransaction(csvStorage{dir: dir}, "a")
+	defer tx.release()
+	_, _, err = tx.updateRecord(record{count: -6, name: "apple", price: Unchanged}, "a", false)
+	if _, ok := err.(*declinedError); !ok {
+		t.Fatalf("want declinedError got: %v", err)
+	}
+	recs, err := tx.loadRecords("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs[0].count != 5 {
+		t.Fatalf("declined update changed staged records: %v", recs)
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.csv":             "5,apple,-1",
+		"a.csv.tmp":         "3,apple,-1",
+		"b.csv.tmp":         "2,apple,-1",
+		"c.csv":             "1,pear,-1",
+		"c.csv.tmp":         "0,pear,-1",
+		"journal.1.csv":     "a.csv.tmp,a.csv\nb.csv.tmp,b.csv\n",
+		"d.csv":             "1,sword,-1",
+		"journal.2.csv.tmp": "",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// c.csv.tmp is not in the journal so it must be discarded.
+	if err := replayJournal(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"a.csv": "3,apple,-1",
+		"b.csv": "2,apple,-1",
+		"c.csv": "1,pear,-1",
+		"d.csv": "1,sword,-1",
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("want %v files got: %v", len(want), entries)
+	}
+	for name, w := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != w {
+			t.Fatalf("%v want: %v got: %v\n", name, w, string(got))
+		}
+	}
+}
+
+func TestReplayJournalLedger(t *testing.T) {
+	// The ledger may have none, some, or all of the entries appended before
+	// the process died.
+	for _, ledger := range []string{"old\n", "old\nne", "old\nnew\n"} {
+		dir := t.TempDir()
+		files := map[string]string{
+			"a.csv.tmp":        "3,apple,-1",
+			"ledger.log":       ledger,
+			"ledger.log.1.tmp": "new\n",
+			"journal.1.csv":    "a.csv.tmp,a.csv\nledger.log.1.tmp,ledger.log,4\n",
+		}
+		for name, data := range files {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := replayJournal(dir); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{
+			"a.csv":      "3,apple,-1",
+			"ledger.log": "old\nnew\n",
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("want %v files got: %v", len(want), entries)
+		}
+		for name, w := range want {
+			got, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != w {
+				t.Fatalf("%v want: %q got: %q\n", name, w, string(got))
+			}
+		}
+	}
+}
+
+func TestTransactionUnlockedOwner(t *testing.T) {
+	tx := newTransaction(csvStorage{dir: t.TempDir()}, "a")
+	defer tx.release()
+	_, _, err := tx.updateRecord(record{count: 1, name: "apple", price: Unchanged}, "b", false)
+	if err == nil {
+		t.Fatal("updated an inventory which was not locked")
+	}
+}
+
+func TestUpdateRecordConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	const workers = 20
+	const updates = 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				_, _, err := updateRecord(
+					record{count: 1, name: "apple", price: Unchanged},
+					csvStorage{dir: dir},
+					"owner",
+					false,
+				)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				// Buys lock two inventories in opposite orders.
+				buyer, seller := "a", "b"
+				if i%2 == 0 {
+					buyer, seller = seller, buyer
+				}
+				tx := newTransaction(csvStorage{dir: dir}, buyer, seller)
+				_, _, err = tx.updateRecord(
+					record{count: 1, name: "apple", price: Unchanged},
+					buyer,
+					false,
+				)
+				if err == nil {
+					_, _, err = tx.updateRecord(
+						record{count: 1, name: "apple", price: Unchanged},
+						seller,
+						false,
+					)
+				}
+				if err == nil {
+					err = tx.commit()
+				}
+				tx.release()
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprintf("%v,apple,-1", workers*updates)
+	for _, owner := range []string{"owner", "a", "b"} {
+		got, err := os.ReadFile(filepath.Join(dir, owner+".csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(got)) != want {
+			t.Fatalf("%v want: %v got: %v\n", owner, want, string(got))
+		}
+	}
+}
@@ -1,80 +1,50 @@
 package main
 
-import (
-	"bufio"
-	"bytes"
-	"errors"
-	"fmt"
-	"io/fs"
-	"log"
-	"os"
-	"path/filepath"
-	"strings"
-)
+import "log"
 
 // description returns the description of an item.
 func (b backpack) description(item string) string {
-	descriptions, err := b.loadDescriptions()
+	descriptions, err := b.storage().loadDescriptions()
 	if err != nil {
 		log.Printf("error loading descriptions: %v\n", err)
 		return FatalMessage
 	}
-	return displayName(item, 1) + ": " + descriptions[normalizeName(item)]
+	id := b.itemID(item)
+	return b.catalog.name(id, 1) + ": " + descriptions[id]
 }
 
 // setDescription updates the description of an item.
 func (b backpack) setDescription(item, description string) string {
-	descriptions, err := b.loadDescriptions()
-	if err != nil {
-		log.Printf("error loading descriptions: %v\n", err)
-		return FatalMessage
-	}
-
-	descriptions[normalizeName(item)] = description
-	err = b.storeDescriptions(descriptions)
+	id := b.itemID(item)
+	err := b.updateDescriptions(func(descriptions map[string]string) error {
+		descriptions[id] = description
+		return nil
+	})
 	if err != nil {
 		log.Printf("error storing descriptions: %v\n", err)
 		return FatalMessage
 	}
-	return "Updated description of " + item + "."
+	if err := b.describeEntry(id); err != nil {
+		log.Printf("error recording description in ledger: %v\n", err)
+	}
+	return "Updated description of " + b.catalog.name(id, 1) + "."
 }
 
-// loadDescriptions returns a mapping of items to descriptions.
-func (b backpack) loadDescriptions() (map[string]string, error) {
-	descriptions := make(map[string]string)
-	file, err := os.Open(filepath.Join(b.dir, "descriptions.kv"))
-	if errors.Is(err, fs.ErrNotExist) {
-		return descriptions, nil
-	} else if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+// updateDescriptions loads every description, passes them to update, and
+// stores the result. The descriptions are locked while update runs so two
+// changes made at once can't undo each other. If update returns an error
+// nothing is stored.
+func (b backpack) updateDescriptions(update func(map[string]string) error) error {
+	storage := b.storage()
+	unlock := inventoryLocks.lock(dataKey(storage, "descriptions"))
+	defer unlock()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		a := strings.Split(line, "=")
-		if len(a) != 2 {
-			return nil, fmt.Errorf("invalid description: %v", line)
-		}
-		descriptions[a[0]] = a[1]
+	descriptions, err := storage.loadDescriptions()
+	if err != nil {
+		return err
 	}
-
-	return descriptions, nil
-}
-
-// storeDescriptions stores a mapping of items to descriptions.
-func (b backpack) storeDescriptions(descriptions map[string]string) error {
-	var buf bytes.Buffer
-	for item, description := range descriptions {
-		buf.WriteString(item)
-		buf.WriteString("=")
-		buf.WriteString(description)
-		buf.WriteString("\n")
+	if err := update(descriptions); err != nil {
+		return err
 	}
-	return os.WriteFile(
-		filepath.Join(b.dir, "descriptions.kv"),
-		buf.Bytes(),
-		0777,
-	)
+	return storage.storeDescriptions(descriptions)
 }
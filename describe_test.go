@@ -0,0 +1,45 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSetDescriptionConcurrent(t *testing.T) {
+	for _, backend := range []string{csvBackend, sqliteBackend} {
+		b := backpack{dir: t.TempDir(), backend: backend}
+
+		const n = 20
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				item := fmt.Sprintf("item %v", i)
+				reply := b.setDescription(item, "Number "+fmt.Sprint(i))
+				if reply == FatalMessage {
+					t.Errorf("%v: failed describing %v", backend, item)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		descriptions, err := b.storage().loadDescriptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(descriptions) != n {
+			t.Fatalf("%v: want %v descriptions got %v: %v",
+				backend, n, len(descriptions), descriptions)
+		}
+		for i := 0; i < n; i++ {
+			item := fmt.Sprintf("item %v", i)
+			if descriptions[item] != "Number "+fmt.Sprint(i) {
+				t.Fatalf("%v: lost description of %v", backend, item)
+			}
+		}
+	}
+}
@@ -1,27 +1,130 @@
 package main
 
 import (
+	"fmt"
 	"log"
-	"path/filepath"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/gertd/go-pluralize"
 )
 
-// displayInvetory returns a pretty table showing owner's inventory.
+// displayInvetory returns a pretty table showing the first page of owner's
+// inventory, noting if there are more pages so replies stay short enough to
+// send.
 func (b backpack) displayInvetory(owner string, pricedOnly bool) string {
-	path := filepath.Join(b.dir, owner+".csv")
-	recs, err := loadRecords(path)
+	var pages []string
+	if pricedOnly {
+		recs, err := b.storage().loadRecords(owner)
+		if err != nil {
+			log.Printf("error displaying inventory %v: %v\n", owner, err)
+			return FatalMessage
+		}
+		pages = recs.forSale().pages(b.view(), pageLimit)
+	} else {
+		pages = b.inventoryPages(owner, "", "", "")
+	}
+	if len(pages) > 1 {
+		return pages[0] + fmt.Sprintf("\nView %v to see all %v pages.", owner, len(pages))
+	}
+	return pages[0]
+}
+
+// inventory is an owner's inventory as a view shows it.
+type inventory struct {
+	owner string
+
+	// shown are the records the view shows, already filtered and sorted.
+	shown records
+
+	// group splits the records into sections if given.
+	group string
+
+	// load describes how much the whole inventory weighs, if anything.
+	load string
+}
+
+// loadInventory reads the inventory a view shows. If filter is given only items
+// with that category, rarity, or tag are shown. Items are sorted by order, or
+// else by owner's default sort. If the inventory can't be shown a message
+// saying why is returned instead.
+func (b backpack) loadInventory(q viewQuery) (inventory, string) {
+	recs, err := b.storage().loadRecords(q.owner)
 	if err != nil {
-		log.Printf("error displaying inventory %v: %v\n", owner, err)
-		return FatalMessage
+		log.Printf("error displaying inventory %v: %v\n", q.owner, err)
+		return inventory{}, FatalMessage
 	}
-	if pricedOnly {
-		return recs.forSale().String()
+	st, err := b.loadSettings()
+	if err != nil {
+		log.Printf("error displaying inventory %v: %v\n", q.owner, err)
+		return inventory{}, FatalMessage
+	}
+
+	shown := recs
+	if q.filter != "" {
+		shown = recs.filter(b.catalog, q.filter)
+		if len(shown) == 0 {
+			return inventory{}, fmt.Sprintf(
+				"%v has no %v items",
+				q.owner,
+				normalizeLabel(q.filter),
+			)
+		}
+	}
+	order := q.order
+	if order == "" {
+		order = st.Sorts[q.owner]
+	}
+	var changed map[string]time.Time
+	if order == sortRecent {
+		changed, err = b.changed(q.owner)
+		if err != nil {
+			log.Printf("error displaying inventory %v: %v\n", q.owner, err)
+			return inventory{}, FatalMessage
+		}
+	}
+	return inventory{
+		owner: q.owner,
+		shown: shown.sorted(order, b.catalog, changed),
+		group: q.group,
+		// The weight is always of the whole inventory.
+		load: b.load(q.owner, recs, st),
+	}, ""
+}
+
+// inventoryPages shows owner's inventory like loadInventory along with how
+// much it weighs, split into pages of tables which fit in a message. If group
+// is given the items are split into a table per category or rarity. There is
+// always at least one page.
+func (b backpack) inventoryPages(owner, filter, group, order string) []string {
+	inv, msg := b.loadInventory(viewQuery{
+		owner:  owner,
+		filter: filter,
+		group:  group,
+		order:  order,
+	})
+	if msg != "" {
+		return []string{msg}
+	}
+	return b.tablePages(inv)
+}
+
+// tablePages splits an inventory into pages of tables which fit in a message.
+func (b backpack) tablePages(inv inventory) []string {
+	var pages []string
+	if inv.group != "" {
+		pages = pack(inv.shown.grouped(b.view(), inv.group, pageLimit), pageLimit)
+	} else {
+		pages = inv.shown.pages(b.view(), pageLimit)
+	}
+	if inv.load != "" {
+		for i := range pages {
+			pages[i] += "\n" + inv.load
+		}
 	}
-	return recs.String()
+	return pages
 }
 
 // displayName capitalizes the first letter of the first word in an item's name.
@@ -0,0 +1,222 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/dustin/go-humanize"
+)
+
+// Ways a guild may choose to show inventories and receipts.
+const (
+	// renderText shows tables drawn in a code block.
+	renderText = "text"
+
+	// renderEmbed shows discord embeds with a field per item or section.
+	renderEmbed = "embed"
+)
+
+// embedColor is the stripe down the side of backpack's embeds.
+const embedColor = 0x8b5a2b
+
+// Limits discord puts on embeds.
+const (
+	embedTitleLimit      = 256
+	embedFieldLimit      = 25
+	embedFieldNameLimit  = 256
+	embedFieldValueLimit = 1024
+
+	// embedPageLimit is the most characters of fields put in an embed,
+	// leaving room under discord's limit of 6000 for everything else.
+	embedPageLimit = 5000
+)
+
+// truncate shortens s to at most limit characters.
+func truncate(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return string(r[:limit-1]) + "…"
+}
+
+// field describes the record as an embed field.
+func (r record) field(v view) *discordgo.MessageEmbedField {
+	value := "Quantity: " + humanize.Comma(int64(r.count))
+	if r.price != NotForSale && r.price != Unchanged {
+		value += "\nPrice: " + v.currency.format(r.price)
+	}
+	if r.offer > 0 {
+		value += "\nOffer: " + v.currency.format(r.offer)
+	}
+	return &discordgo.MessageEmbedField{
+		Name:   truncate(v.catalog.name(r.name, r.count), embedFieldNameLimit),
+		Value:  value,
+		Inline: true,
+	}
+}
+
+// fields describes a section as embed fields with a line per record. Sections
+// too long for one field continue in the next.
+func (s section) fields(v view) []*discordgo.MessageEmbedField {
+	name := truncate(displayName(s.title, 2), embedFieldNameLimit)
+	var fields []*discordgo.MessageEmbedField
+	var lines []string
+	var size int
+	flush := func() {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  name,
+			Value: strings.Join(lines, "\n"),
+		})
+		lines, size = nil, 0
+	}
+	for _, r := range s.recs {
+		line := truncate(r.display(v), embedFieldValueLimit)
+		if len(lines) > 0 && size+len("\n")+len(line) > embedFieldValueLimit {
+			flush()
+		}
+		lines = append(lines, line)
+		size += len("\n") + len(line)
+	}
+	if len(lines) > 0 {
+		flush()
+	}
+	return fields
+}
+
+// inventoryEmbeds shows an inventory as embeds titled with the owner's name,
+// as written by label. Items, or sections if the inventory is grouped, each
+// get a field and are split across as many embeds as they need. The footer
+// holds the total value, coins, and weight. There is always at least one embed.
+func (b backpack) inventoryEmbeds(inv inventory, label func(string) string) []*discordgo.MessageEmbed {
+	v := b.view()
+	var fields []*discordgo.MessageEmbedField
+	if inv.group != "" {
+		for _, s := range inv.shown.group(v.catalog, inv.group) {
+			fields = append(fields, s.fields(v)...)
+		}
+	} else {
+		for _, r := range inv.shown {
+			if r.count != 0 {
+				fields = append(fields, r.field(v))
+			}
+		}
+	}
+
+	footer := worth(inv.shown, v)
+	if inv.load != "" {
+		footer = append(footer, inv.load)
+	}
+	embed := func(fields []*discordgo.MessageEmbedField) *discordgo.MessageEmbed {
+		e := &discordgo.MessageEmbed{
+			Title:  truncate(label(inv.owner), embedTitleLimit),
+			Color:  embedColor,
+			Fields: fields,
+		}
+		if len(fields) == 0 {
+			e.Description = "Nothing here."
+		}
+		if len(footer) > 0 {
+			e.Footer = &discordgo.MessageEmbedFooter{
+				Text: strings.Join(footer, " · "),
+			}
+		}
+		return e
+	}
+
+	var embeds []*discordgo.MessageEmbed
+	var page []*discordgo.MessageEmbedField
+	var size int
+	for _, f := range fields {
+		n := len(f.Name) + len(f.Value)
+		if len(page) == embedFieldLimit || (len(page) > 0 && size+n > embedPageLimit) {
+			embeds = append(embeds, embed(page))
+			page, size = nil, 0
+		}
+		page = append(page, f)
+		size += n
+	}
+	if len(page) > 0 || len(embeds) == 0 {
+		embeds = append(embeds, embed(page))
+	}
+	return embeds
+}
+
+// worth describes the total value of the items for sale in recs and the coins
+// among them, leaving out whichever is nothing.
+func worth(recs records, v view) []string {
+	var parts []string
+	if value := recs.value(); value > 0 {
+		parts = append(parts, "Value: "+v.currency.format(value))
+	}
+	if wealth := v.currency.wealth(recs); wealth > 0 {
+		parts = append(parts, "Coins: "+v.currency.formatCoins(wealth))
+	}
+	return parts
+}
+
+// receipt shows the reply to a change of owner's inventory as an embed titled
+// with the owner's name, as written by label. The footer holds the owner's
+// total worth.
+func (b backpack) receipt(msg, owner string, label func(string) string) *discordgo.MessageEmbed {
+	e := &discordgo.MessageEmbed{
+		Title:       truncate(label(owner), embedTitleLimit),
+		Description: msg,
+		Color:       embedColor,
+	}
+	recs, err := b.storage().loadRecords(owner)
+	if err != nil {
+		log.Printf("error loading inventory %v for receipt: %v\n", owner, err)
+		return e
+	}
+	if footer := worth(recs, b.view()); len(footer) > 0 {
+		e.Footer = &discordgo.MessageEmbedFooter{
+			Text: strings.Join(footer, " · "),
+		}
+	}
+	return e
+}
+
+// reply answers a change of owner's inventory in the guild's chosen style.
+func (b backpack) reply(msg, owner string, s *discordgo.Session, m *discordgo.InteractionCreate) {
+	if b.render != renderEmbed {
+		say(msg, s, m)
+		return
+	}
+	s.InteractionRespond(m.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				b.receipt(msg, owner, stateLabel(s, m.GuildID)),
+			},
+		},
+	})
+}
+
+// setRender changes how the guild's inventories and receipts are shown.
+func (b backpack) setRender(style string) string {
+	log.Println("render", style)
+	if style != renderText && style != renderEmbed {
+		return fmt.Sprintf(
+			"Please choose either %v or %v.",
+			renderText,
+			renderEmbed,
+		)
+	}
+	err := b.updateSettings(func(st *settings) error {
+		st.Render = style
+		return nil
+	})
+	if err != nil {
+		log.Printf("error setting render style: %v\n", err)
+		return FatalMessage
+	}
+	if style == renderEmbed {
+		return "Inventories and receipts are now shown as embeds."
+	}
+	return "Inventories and receipts are now shown as text."
+}
@@ -0,0 +1,203 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInventoryEmbeds(t *testing.T) {
+	b := backpack{
+		currency: testCurrency,
+		catalog: catalog{
+			"longsword":      {Category: "weapon", Weight: 3},
+			"healing potion": {Category: "consumable"},
+		},
+	}
+	label := func(owner string) string { return "#" + owner }
+	inv := inventory{
+		owner: "shop",
+		shown: records{
+			{count: 1, name: "longsword", price: 150000},
+			{count: 3, name: "healing potion", price: 5000, offer: 2500},
+			{count: 0, name: "rope", price: NotForSale},
+			{count: 25, name: "silver piece", price: NotForSale},
+		},
+		load: "Weight: 3",
+	}
+
+	embeds := b.inventoryEmbeds(inv, label)
+	if len(embeds) != 1 {
+		t.Fatalf("want 1 embed got %v", len(embeds))
+	}
+	e := embeds[0]
+	if e.Title != "#shop" {
+		t.Fatalf("incorrect title: %v", e.Title)
+	}
+	var got []string
+	for _, f := range e.Fields {
+		got = append(got, f.Name+": "+strings.ReplaceAll(f.Value, "\n", ", "))
+	}
+	want := []string{
+		"Longsword: Quantity: 1, Price: 15gp",
+		"Healing potions: Quantity: 3, Price: 5sp, Offer: 2sp 5cp",
+		"Silver pieces: Quantity: 25",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("incorrect fields:\nwant:\n%v\ngot:\n%v",
+			strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if e.Footer == nil || e.Footer.Text != "Value: 16gp 5sp · Coins: 2gp 5sp · Weight: 3" {
+		t.Fatalf("incorrect footer: %+v", e.Footer)
+	}
+
+	inv.group = groupCategory
+	e = b.inventoryEmbeds(inv, label)[0]
+	got = nil
+	for _, f := range e.Fields {
+		got = append(got, f.Name+": "+strings.ReplaceAll(f.Value, "\n", ", "))
+	}
+	want = []string{
+		"Consumables: 3 Healing potions for sale for 5sp bought for 2sp 5cp",
+		"Weapons: 1 Longsword for sale for 15gp",
+		"Others: 25 Silver pieces",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("incorrect grouped fields:\nwant:\n%v\ngot:\n%v",
+			strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	inv = inventory{owner: "shop", shown: inv.shown[:2]}
+	e = b.inventoryEmbeds(inv, label)[0]
+	if e.Footer == nil || e.Footer.Text != "Value: 16gp 5sp" {
+		t.Fatalf("incorrect footer without coins: %+v", e.Footer)
+	}
+
+	e = b.inventoryEmbeds(inventory{owner: "empty"}, label)[0]
+	if e.Description != "Nothing here." || e.Footer != nil {
+		t.Fatalf("incorrect empty embed: %+v", e)
+	}
+}
+
+func TestInventoryEmbedsPages(t *testing.T) {
+	var recs records
+	for i := 0; i < 60; i++ {
+		recs = append(recs, record{
+			count: i + 1,
+			name:  fmt.Sprintf("item %03d", i),
+			price: NotForSale,
+		})
+	}
+	b := backpack{}
+	label := func(owner string) string { return owner }
+
+	embeds := b.inventoryEmbeds(inventory{owner: "shop", shown: recs}, label)
+	if len(embeds) != 3 {
+		t.Fatalf("want 3 embeds got %v", len(embeds))
+	}
+	for _, e := range embeds {
+		if len(e.Fields) > embedFieldLimit {
+			t.Fatalf("embed has %v fields", len(e.Fields))
+		}
+	}
+
+	// A long section is split across several fields.
+	var long records
+	for i := 0; i < 100; i++ {
+		long = append(long, record{
+			count: 1,
+			name:  fmt.Sprintf("a rather long item name %03d", i),
+			price: NotForSale,
+		})
+	}
+	embeds = b.inventoryEmbeds(
+		inventory{owner: "shop", shown: long, group: groupCategory},
+		label,
+	)
+	var n int
+	for _, e := range embeds {
+		for _, f := range e.Fields {
+			if len(f.Value) > embedFieldValueLimit {
+				t.Fatalf("field is %v characters long", len(f.Value))
+			}
+			n += strings.Count(f.Value, "\n") + 1
+		}
+	}
+	if n != len(long) {
+		t.Fatalf("want %v items got %v", len(long), n)
+	}
+}
+
+func TestViewPageEmbed(t *testing.T) {
+	dir := t.TempDir()
+	var inventory bytes.Buffer
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&inventory, "%v,item %03d,-1\n", i+1, i)
+	}
+	err := os.WriteFile(filepath.Join(dir, "shop.csv"), inventory.Bytes(), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backpack{dir: dir}
+	if reply := b.setRender(renderEmbed); reply != "Inventories and receipts are now shown as embeds." {
+		t.Fatalf("incorrect reply: %v", reply)
+	}
+	st, err := b.loadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.render = st.Render
+
+	data := b.viewPage(viewQuery{owner: "shop"}, 2, func(owner string) string {
+		return owner
+	})
+	if data.Content != "" || len(data.Embeds) != 1 {
+		t.Fatalf("want a single embed got %+v", data)
+	}
+	e := data.Embeds[0]
+	if len(e.Fields) != 5 || e.Footer == nil || e.Footer.Text != "Page 2 of 2" {
+		t.Fatalf("incorrect second page: %v fields, footer %+v", len(e.Fields), e.Footer)
+	}
+	if len(data.Components) != 1 {
+		t.Fatalf("missing page buttons: %+v", data.Components)
+	}
+
+	if reply := b.setRender("fancy"); !strings.HasPrefix(reply, "Please choose") {
+		t.Fatalf("invalid style accepted: %v", reply)
+	}
+}
+
+func TestReceipt(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "buyer.csv"), []byte("25,silver piece,-1"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backpack{dir: dir, currency: testCurrency}
+	e := b.receipt("buyer bought 1 Longsword", "buyer", func(owner string) string {
+		return "@" + owner
+	})
+	if e.Title != "@buyer" || e.Description != "buyer bought 1 Longsword" {
+		t.Fatalf("incorrect receipt: %+v", e)
+	}
+	if e.Footer == nil || e.Footer.Text != "Coins: 2gp 5sp" {
+		t.Fatalf("incorrect footer: %+v", e.Footer)
+	}
+
+	// A shop with items for sale but no coins is still worth something.
+	err = os.WriteFile(filepath.Join(dir, "shop.csv"), []byte("2,longsword,150"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = b.receipt("shop has 2 Longswords", "shop", func(owner string) string {
+		return owner
+	})
+	if e.Footer == nil || e.Footer.Text != "Value: 3gp" {
+		t.Fatalf("incorrect footer: %+v", e.Footer)
+	}
+}
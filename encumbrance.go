@@ -0,0 +1,145 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+)
+
+// Encumbrance modes which may be chosen for a guild.
+const (
+	// encumbranceWarn allows over-encumbering an owner, but says so.
+	encumbranceWarn = "warn"
+
+	// encumbranceDecline declines changes which over-encumber an owner.
+	encumbranceDecline = "decline"
+)
+
+// weigh returns the total weight of recs. Items without a weight weigh
+// nothing.
+func (c catalog) weigh(recs records) float64 {
+	var total float64
+	for _, r := range recs {
+		if r.count > 0 {
+			total += float64(r.count) * c[r.name].Weight
+		}
+	}
+	return total
+}
+
+// formatWeight writes a weight without needless decimals.
+func formatWeight(w float64) string {
+	return strconv.FormatFloat(math.Round(w*100)/100, 'f', -1, 64)
+}
+
+// load describes how much owner carries compared to their capacity. It is
+// empty if none of the items have a weight and owner has no capacity.
+func (b backpack) load(owner string, recs records, st settings) string {
+	capacity, ok := st.Capacities[owner]
+	weight := b.catalog.weigh(recs)
+	if !ok && weight == 0 {
+		return ""
+	}
+	if !ok {
+		return "Weight: " + formatWeight(weight)
+	}
+	return fmt.Sprintf(
+		"Weight: %v / %v",
+		formatWeight(weight),
+		formatWeight(capacity),
+	)
+}
+
+// checkEncumbrance weighs owner's inventory as staged in tx. If owner carries
+// more than their capacity a message is returned, and declined is set if the
+// guild declines changes which over-encumber.
+func (b backpack) checkEncumbrance(tx *transaction, owner string) (msg string, declined bool, err error) {
+	st, err := b.loadSettings()
+	if err != nil {
+		return "", false, err
+	}
+	capacity, ok := st.Capacities[owner]
+	if !ok {
+		return "", false, nil
+	}
+	recs, err := tx.loadRecords(owner)
+	if err != nil {
+		return "", false, err
+	}
+	weight := b.catalog.weigh(recs)
+	if weight <= capacity {
+		return "", false, nil
+	}
+	if st.Encumbrance == encumbranceDecline {
+		return fmt.Sprintf(
+			"%v can't carry that, it would carry %v of %v",
+			owner,
+			formatWeight(weight),
+			formatWeight(capacity),
+		), true, nil
+	}
+	return fmt.Sprintf(
+		"%v is over-encumbered, carrying %v of %v",
+		owner,
+		formatWeight(weight),
+		formatWeight(capacity),
+	), false, nil
+}
+
+// setCapacity limits how much owner can carry. A negative capacity removes
+// the limit. mode, if given, changes what happens to every owner who carries
+// too much.
+func (b backpack) setCapacity(capacity float64, owner, mode string) string {
+	log.Println(owner, "capacity", capacity, mode)
+	err := b.updateSettings(func(st *settings) error {
+		if mode != "" {
+			st.Encumbrance = mode
+		}
+		if capacity < 0 {
+			delete(st.Capacities, owner)
+			return nil
+		}
+		if st.Capacities == nil {
+			st.Capacities = make(map[string]float64)
+		}
+		st.Capacities[owner] = capacity
+		return nil
+	})
+	if err != nil {
+		log.Printf("error setting capacity of %v: %v\n", owner, err)
+		return FatalMessage
+	}
+	if capacity < 0 {
+		return fmt.Sprintf("%v can carry any amount", owner)
+	}
+	return fmt.Sprintf("%v can carry %v", owner, formatWeight(capacity))
+}
+
+// setWeight changes how much one of an item weighs. A weight of 0 makes the
+// item weightless.
+func (b backpack) setWeight(item string, weight float64) string {
+	log.Println("weight", item, weight)
+	if item == "" {
+		return "You forgot to request an item."
+	}
+	if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return "Invalid weight. Please use a positive number."
+	}
+	id := b.itemID(item)
+	_, err := b.updateCatalog(id, func(c catalog, it *catalogItem) error {
+		it.Weight = weight
+		return nil
+	})
+	if err != nil {
+		log.Printf("error setting weight of %v: %v\n", id, err)
+		return FatalMessage
+	}
+	return fmt.Sprintf(
+		"%v weighs %v",
+		b.catalog.name(id, 1),
+		formatWeight(weight),
+	)
+}
@@ -0,0 +1,143 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncumbrance(t *testing.T) {
+	type test struct {
+		mode  string
+		op    string
+		count int
+		item  string
+		begin string
+
+		wantReply string
+		want      string
+	}
+
+	tests := []test{
+		{
+			mode:  encumbranceWarn,
+			op:    "add",
+			count: 2,
+			item:  "anvils",
+			begin: "10,arrow,-1",
+			wantReply: "Added 2 Anvils\n" +
+				"owner has 2 Anvils\n" +
+				"owner is over-encumbered, carrying 200.5 of 150",
+			want: "10,arrow,-1\n2,anvil,-1",
+		},
+		{
+			mode:      encumbranceDecline,
+			op:        "add",
+			count:     2,
+			item:      "anvils",
+			begin:     "10,arrow,-1",
+			wantReply: "owner can't carry that, it would carry 200.5 of 150",
+			want:      "10,arrow,-1",
+		},
+		{
+			mode:  encumbranceDecline,
+			op:    "add",
+			count: 1,
+			item:  "anvil",
+			begin: "10,arrow,-1",
+			wantReply: "Added 1 Anvil\n" +
+				"owner has 1 Anvil",
+			want: "10,arrow,-1\n1,anvil,-1",
+		},
+		{
+			// Lightening the load is always allowed.
+			mode:  encumbranceDecline,
+			op:    "remove",
+			count: 1,
+			item:  "anvil",
+			begin: "3,anvil,-1",
+			wantReply: "Removed 1 Anvil\n" +
+				"owner has 2 Anvils",
+			want: "2,anvil,-1",
+		},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "owner.csv")
+		if err := os.WriteFile(path, []byte(tc.begin), 0600); err != nil {
+			t.Fatal(err)
+		}
+		b := backpack{
+			dir: dir,
+			catalog: catalog{
+				"anvil": {Weight: 100},
+				"arrow": {Weight: 0.05},
+			},
+		}
+		b.setCapacity(150, "owner", tc.mode)
+
+		reply := b.modifyItem(tc.count, Unchanged, tc.item, "owner", tc.op)
+		if reply != tc.wantReply {
+			t.Fatalf(
+				"incorrect reply:\nwant:\n%v\ngot:\n%v\n",
+				tc.wantReply,
+				reply,
+			)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(got)) != tc.want {
+			t.Fatalf(
+				"incorrect inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.want,
+				string(got),
+			)
+		}
+	}
+}
+
+func TestBuyItemEncumbrance(t *testing.T) {
+	dir := t.TempDir()
+	buyerPath := filepath.Join(dir, "buyer.csv")
+	if err := os.WriteFile(buyerPath, []byte("50,coin,-1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sellerPath := filepath.Join(dir, "seller.csv")
+	if err := os.WriteFile(sellerPath, []byte("5,anvil,10"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b := backpack{
+		dir:     dir,
+		catalog: catalog{"anvil": {Weight: 100}},
+	}
+	b.setCapacity(150, "buyer", encumbranceDecline)
+
+	reply := b.buyItem(2, "anvils", "buyer", "seller")
+	want := "buyer can't carry that, it would carry 200 of 150"
+	if reply != want {
+		t.Fatalf("incorrect reply:\nwant:\n%v\ngot:\n%v\n", want, reply)
+	}
+	got, err := os.ReadFile(buyerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(got)) != "50,coin,-1" {
+		t.Fatalf("buyer inventory changed:\n%v", string(got))
+	}
+
+	reply = b.displayInvetory("seller", false)
+	if !strings.HasSuffix(reply, "```\nWeight: 500") {
+		t.Fatalf("missing weight:\n%v", reply)
+	}
+	b.buyItem(1, "anvil", "buyer", "seller")
+	reply = b.displayInvetory("buyer", false)
+	if !strings.HasSuffix(reply, "```\nWeight: 100 / 150") {
+		t.Fatalf("missing weight and capacity:\n%v", reply)
+	}
+}
@@ -0,0 +1,88 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+// giveItem moves items or coins from one inventory to another without any
+// payment. Either both inventories change or neither does.
+func (b backpack) giveItem(count int, item, from, to string) string {
+	log.Println(from, "gave", count, item, "to", to)
+
+	if from == to {
+		return fmt.Sprintf(
+			"bruh. alright...\n%v gave %v %v to %v",
+			from,
+			count, item,
+			to,
+		)
+	}
+
+	// Request count should always be greater than 0!
+	if count == 0 {
+		return "You can't give 0 of an item, silly!"
+	} else if count < 0 {
+		return "You can't give a negative number of items, silly!"
+	}
+
+	name := b.itemID(item)
+	itemFromGiver := record{
+		count: -count,
+		name:  name,
+		price: Unchanged,
+	}
+	itemToReceiver := record{
+		count: count,
+		name:  name,
+		price: Unchanged,
+	}
+
+	var response bytes.Buffer
+	tx := b.begin("give", from, to)
+	defer tx.release()
+
+	fromUpdated, _, err := tx.updateRecord(itemFromGiver, from, false)
+	if _, ok := err.(*declinedError); ok {
+		// Transaction declined. Giver doesn't have enough.
+		response.WriteString(fmt.Sprintf(
+			"%v does not have %v to give\n",
+			from,
+			itemToReceiver.display(b.view()),
+		))
+		response.WriteString("Please choose one of the following items:\n")
+		response.WriteString(b.displayInvetory(from, false))
+		return response.String()
+	} else if err != nil {
+		// Fatal error.
+		log.Println(err)
+		return FatalMessage
+	}
+
+	toUpdated, _, err := tx.updateRecord(itemToReceiver, to, false)
+	if _, ok := err.(*overflowError); ok {
+		return OverflowMessage
+	} else if err != nil {
+		log.Printf("error in give request %v %v: "+
+			"failed to give %v to receiver: %v\n", count, item, itemToReceiver, err)
+		return FatalMessage
+	}
+
+	if err := tx.commit(); err != nil {
+		log.Printf("error in give request %v %v: %v\n", count, item, err)
+		return FatalMessage
+	}
+
+	response.WriteString(fmt.Sprintf(
+		"%v gave %v to %v\n",
+		from,
+		itemToReceiver.display(b.view()),
+		to,
+	))
+	response.WriteString(fmt.Sprintf("%v has %v\n", from, fromUpdated.display(b.view())))
+	response.WriteString(fmt.Sprintf("%v has %v", to, toUpdated.display(b.view())))
+	return response.String()
+}
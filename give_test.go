@@ -0,0 +1,123 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGiveItem(t *testing.T) {
+	type test struct {
+		count int
+		item  string
+		from  string
+		to    string
+
+		wantReply string
+		fromWant  string
+		toWant    string
+	}
+
+	tests := []test{
+		{
+			count: 10,
+			item:  "apples",
+			from:  "20,apple,1",
+			to:    "5,coin,-1",
+			wantReply: "from gave 10 Apples to to\n" +
+				"from has 10 Apples for sale for $1\n" +
+				"to has 10 Apples",
+			fromWant: "10,apple,1",
+			toWant:   "5,coin,-1\n10,apple,-1",
+		},
+		{
+			count: 5,
+			item:  "coins",
+			from:  "5,coin,-1",
+			to:    "1,coin,-1",
+			wantReply: "from gave 5 Coins to to\n" +
+				"from has 0 Coins\n" +
+				"to has 6 Coins",
+			fromWant: "0,coin,-1",
+			toWant:   "6,coin,-1",
+		},
+		{
+			count: 2,
+			item:  "swords",
+			from:  "1,sword,-1",
+			to:    "",
+			wantReply: "from does not have 2 Swords to give\n" +
+				"Please choose one of the following items:\n" +
+				"```\n" +
+				"╔═════════════════╗\n" +
+				"║ Quantity  Item  ║\n" +
+				"║─────────────────║\n" +
+				"║ 1         Sword ║\n" +
+				"╚═════════════════╝\n" +
+				"```",
+			fromWant: "1,sword,-1",
+			toWant:   "",
+		},
+		{
+			count:     0,
+			item:      "apples",
+			from:      "20,apple,1",
+			to:        "",
+			wantReply: "You can't give 0 of an item, silly!",
+			fromWant:  "20,apple,1",
+			toWant:    "",
+		},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		fromPath := filepath.Join(dir, "from.csv")
+		err := os.WriteFile(fromPath, []byte(tc.from), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toPath := filepath.Join(dir, "to.csv")
+		err = os.WriteFile(toPath, []byte(tc.to), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := backpack{
+			dir: dir,
+		}
+		reply := b.giveItem(tc.count, tc.item, "from", "to")
+		if tc.wantReply != reply {
+			t.Fatalf(
+				"incorrect reply:\nwant:\n%v\ngot:\n%v\n",
+				tc.wantReply,
+				reply,
+			)
+		}
+
+		fromGot, err := os.ReadFile(fromPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(fromGot)) != tc.fromWant {
+			t.Fatalf(
+				"incorrect giver inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.fromWant,
+				string(fromGot),
+			)
+		}
+		toGot, err := os.ReadFile(toPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(toGot)) != tc.toWant {
+			t.Fatalf(
+				"incorrect receiver inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.toWant,
+				string(toGot),
+			)
+		}
+	}
+}
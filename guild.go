@@ -0,0 +1,98 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// inGuild returns a backpack whose data is kept in a directory of its own for
+// the guild with the given ID. The directory is created if needed.
+func (b backpack) inGuild(guildID string) (backpack, error) {
+	if !isSnowflake(guildID) {
+		return b, fmt.Errorf("invalid guild ID: %q", guildID)
+	}
+	b.dir = filepath.Join(b.dir, guildID)
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return b, fmt.Errorf("failed creating guild directory: %v", err)
+	}
+	return b, nil
+}
+
+// isSnowflake reports whether id looks like a discord ID.
+func isSnowflake(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// guildDirs lists the data directories of every guild in dir.
+func guildDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %v: %v", dir, err)
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() && isSnowflake(e.Name()) {
+			dirs = append(dirs, filepath.Join(dir, e.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// legacyFiles lists the files which older versions of backpack kept directly
+// in the data directory before it was split up per guild.
+func legacyFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %v: %v", dir, err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		files = append(files, e.Name())
+	}
+	return files, nil
+}
+
+// migrateLegacyData moves the files found directly in dir into the data
+// directory of the guild with the given ID. Nothing is moved if any of the
+// files already exist in the guild's directory.
+func migrateLegacyData(dir, guildID string) error {
+	files, err := legacyFiles(dir)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	b, err := backpack{dir: dir}.inGuild(guildID)
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		_, err := os.Stat(filepath.Join(b.dir, name))
+		if err == nil {
+			return fmt.Errorf("%v already exists in %v", name, b.dir)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, name := range files {
+		err := os.Rename(filepath.Join(dir, name), filepath.Join(b.dir, name))
+		if err != nil {
+			return fmt.Errorf("failed moving %v: %v", name, err)
+		}
+	}
+	return syncDir(dir)
+}
@@ -0,0 +1,89 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInGuild(t *testing.T) {
+	b := backpack{dir: t.TempDir()}
+	for _, id := range []string{"", "..", "../123", "1/2"} {
+		if _, err := b.inGuild(id); err == nil {
+			t.Fatalf("accepted invalid guild ID: %q", id)
+		}
+	}
+
+	a, err := b.inGuild("123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := updateRecord(
+		record{count: 1, name: "apple", price: Unchanged},
+		a.storage(),
+		"<#1>",
+		false,
+	); err != nil {
+		t.Fatal(err)
+	}
+	other, err := b.inGuild("456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, err := other.storage().loadRecords("<#1>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 0 {
+		t.Fatalf("inventory leaked between guilds: %v", recs)
+	}
+}
+
+func TestMigrateLegacyData(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"<#1>.csv":        "1,apple,-1",
+		"descriptions.kv": "apple=A red fruit.",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "456"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateLegacyData(dir, "123"); err != nil {
+		t.Fatal(err)
+	}
+
+	left, err := legacyFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Fatalf("files left after migration: %v", left)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, "123", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("%v want: %v got: %v\n", name, want, string(got))
+		}
+	}
+
+	// Migrating into a guild which already has the files must fail.
+	err = os.WriteFile(filepath.Join(dir, "<#1>.csv"), []byte("2,pear,-1"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateLegacyData(dir, "123"); err == nil {
+		t.Fatal("migration overwrote existing guild data")
+	}
+}
@@ -0,0 +1,205 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// historyPageSize is the number of ledger entries shown per page of history.
+const historyPageSize = 10
+
+// ledgerEntry records a single change made by a command. Every change to an
+// inventory or description is appended to the ledger and never modified.
+type ledgerEntry struct {
+	time        time.Time
+	interaction string
+	actor       string
+	op          string
+	owner       string
+	item        string
+	delta       int
+	price       money
+
+	// oldPrice is the item's price before the change. NotForSale is used
+	// if the owner did not have the item.
+	oldPrice money
+
+	// ref is the interaction reverted by an undo.
+	ref string
+}
+
+// String prints out a line describing the change for discord. Time is shown in
+// the reader's timezone and mentions are shown as names.
+func (e ledgerEntry) String() string {
+	return e.display(view{})
+}
+
+// display describes the entry like String, using the names and currency of v.
+func (e ledgerEntry) display(v view) string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("<t:%v:f> ", e.time.Unix()))
+	if e.actor != "" {
+		buf.WriteString("<@" + e.actor + "> ")
+	}
+	buf.WriteString(e.op)
+	if e.owner != "" {
+		buf.WriteString(" " + e.owner)
+	}
+
+	count := e.delta
+	if count < 0 {
+		count = -count
+	}
+	switch e.op {
+	case "describe":
+		buf.WriteString(" " + v.catalog.name(e.item, 1))
+		return buf.String()
+	case "offer":
+		buf.WriteString(" " + v.catalog.name(e.item, 1))
+		if e.price > 0 {
+			buf.WriteString(" at " + v.currency.format(e.price))
+		} else {
+			buf.WriteString(" no longer bought")
+		}
+		return buf.String()
+	}
+	buf.WriteString(fmt.Sprintf(
+		" %v%v %v",
+		sign(e.delta),
+		humanize.Comma(int64(count)),
+		v.catalog.name(e.item, count),
+	))
+	if e.price != NotForSale && e.price != Unchanged {
+		buf.WriteString(" at " + v.currency.format(e.price))
+	}
+	return buf.String()
+}
+
+// sign returns "-" for negative numbers and "+" for the rest.
+func sign(n int) string {
+	if n < 0 {
+		return "-"
+	}
+	return "+"
+}
+
+// fields converts the entry to the fields stored in the ledger.
+func (e ledgerEntry) fields() []string {
+	return []string{
+		e.time.Format(time.RFC3339),
+		e.interaction,
+		e.actor,
+		e.op,
+		e.owner,
+		e.item,
+		strconv.Itoa(e.delta),
+		formatPrice(e.price),
+		formatPrice(e.oldPrice),
+		e.ref,
+	}
+}
+
+// parseLedgerEntry converts fields stored in the ledger back into an entry.
+// Entries written before old prices were recorded have 8 fields and take the
+// current price as their old price.
+func parseLedgerEntry(fields []string) (ledgerEntry, error) {
+	var e ledgerEntry
+	if len(fields) == 8 {
+		fields = append(fields, fields[7], "")
+	}
+	if len(fields) != 10 {
+		return e, fmt.Errorf("invalid ledger entry: %v", fields)
+	}
+	t, err := time.Parse(time.RFC3339, fields[0])
+	if err != nil {
+		return e, fmt.Errorf("failed parsing ledger time: %v", fields[0])
+	}
+	delta, err := strconv.Atoi(fields[6])
+	if err != nil {
+		return e, fmt.Errorf("failed parsing ledger delta: %v", fields[6])
+	}
+	price, err := parsePrice(fields[7])
+	if err != nil {
+		return e, fmt.Errorf("failed parsing ledger price: %v", fields[7])
+	}
+	oldPrice, err := parsePrice(fields[8])
+	if err != nil {
+		return e, fmt.Errorf("failed parsing ledger old price: %v", fields[8])
+	}
+	return ledgerEntry{
+		time:        t,
+		interaction: fields[1],
+		actor:       fields[2],
+		op:          fields[3],
+		owner:       fields[4],
+		item:        fields[5],
+		delta:       delta,
+		price:       price,
+		oldPrice:    oldPrice,
+		ref:         fields[9],
+	}, nil
+}
+
+// history returns a page of the most recent ledger entries, newest first.
+// Entries can be limited to an owner and/or an item.
+func (b backpack) history(owner, item string, page int) string {
+	entries, err := b.storage().loadLedger()
+	if err != nil {
+		log.Printf("error loading ledger: %v\n", err)
+		return FatalMessage
+	}
+
+	name := b.itemID(item)
+	var matched []ledgerEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if owner != "" && e.owner != owner {
+			continue
+		}
+		if item != "" && e.item != name {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	if len(matched) == 0 {
+		return "No history found."
+	}
+
+	pages := (len(matched) + historyPageSize - 1) / historyPageSize
+	if page < 1 || page > pages {
+		return fmt.Sprintf("Page must be between 1 and %v.", pages)
+	}
+	start := (page - 1) * historyPageSize
+	end := start + historyPageSize
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	var buf bytes.Buffer
+	for _, e := range matched[start:end] {
+		buf.WriteString(e.display(b.view()))
+		buf.WriteString("\n")
+	}
+	buf.WriteString(fmt.Sprintf("Page %v of %v", page, pages))
+	return buf.String()
+}
+
+// describeEntry records a change to an item's description in the ledger.
+func (b backpack) describeEntry(item string) error {
+	return b.storage().appendLedger([]ledgerEntry{{
+		time:        time.Now().UTC(),
+		interaction: b.interaction,
+		actor:       b.actor,
+		op:          "describe",
+		item:        item,
+		price:       NotForSale,
+		oldPrice:    NotForSale,
+	}})
+}
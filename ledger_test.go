@@ -0,0 +1,108 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLedgerEntryString(t *testing.T) {
+	type test struct {
+		e    ledgerEntry
+		want string
+	}
+
+	when := time.Unix(1000, 0)
+	tests := []test{
+		{
+			e: ledgerEntry{
+				time:  when,
+				actor: "1",
+				op:    "buy",
+				owner: "<#2>",
+				item:  "apple",
+				delta: 10,
+				price: 500,
+			},
+			want: "<t:1000:f> <@1> buy <#2> +10 Apples at $5",
+		},
+		{
+			e: ledgerEntry{
+				time:  when,
+				actor: "1",
+				op:    "remove",
+				owner: "shop",
+				item:  "coin",
+				delta: -1500,
+				price: NotForSale,
+			},
+			want: "<t:1000:f> <@1> remove shop -1,500 Coins",
+		},
+		{
+			e: ledgerEntry{
+				time:  when,
+				actor: "1",
+				op:    "describe",
+				item:  "apple",
+				price: NotForSale,
+			},
+			want: "<t:1000:f> <@1> describe Apple",
+		},
+	}
+	for _, tc := range tests {
+		got := tc.e.String()
+		if got != tc.want {
+			t.Fatalf("want: %v got: %v\n", tc.want, got)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	b := backpack{
+		dir:         t.TempDir(),
+		actor:       "1",
+		interaction: "2",
+	}
+	b.modifyItem(50, Unchanged, Coin, "buyer", "add")
+	b.modifyItem(20, 300, "apples", "seller", "add")
+	b.buyItem(2, "apple", "buyer", "seller")
+
+	// Strip the timestamps which change with every run.
+	stamp := regexp.MustCompile(`<t:[0-9]+:f> `)
+
+	got := stamp.ReplaceAllString(b.history("buyer", "", 1), "")
+	want := "<@1> buy buyer +2 Apples\n" +
+		"<@1> buy buyer -6 Coins\n" +
+		"<@1> add buyer +50 Coins\n" +
+		"Page 1 of 1"
+	if got != want {
+		t.Fatalf("\nwant:\n%v\ngot:\n%v\n", want, got)
+	}
+
+	got = stamp.ReplaceAllString(b.history("", "apple", 1), "")
+	want = "<@1> buy buyer +2 Apples\n" +
+		"<@1> buy seller -2 Apples at $3\n" +
+		"<@1> add seller +20 Apples at $3\n" +
+		"Page 1 of 1"
+	if got != want {
+		t.Fatalf("\nwant:\n%v\ngot:\n%v\n", want, got)
+	}
+
+	for i := 0; i < historyPageSize; i++ {
+		b.modifyItem(1, Unchanged, fmt.Sprint("gem ", i), "buyer", "add")
+	}
+	got = stamp.ReplaceAllString(b.history("buyer", "", 2), "")
+	want = "<@1> buy buyer +2 Apples\n" +
+		"<@1> buy buyer -6 Coins\n" +
+		"<@1> add buyer +50 Coins\n" +
+		"Page 2 of 2"
+	if got != want {
+		t.Fatalf("\nwant:\n%v\ngot:\n%v\n", want, got)
+	}
+	if got := b.history("buyer", "", 3); got != "Page must be between 1 and 2." {
+		t.Fatalf("want page error got: %v", got)
+	}
+}
@@ -35,8 +35,51 @@ func main() {
 		log.Fatalf("error reading data directory: %v: %v\n", dir, err)
 	}
 
+	backend := os.Getenv("BACKPACK_STORAGE")
+	if backend == "" {
+		backend = csvBackend
+	}
+	if backend != csvBackend && backend != sqliteBackend {
+		log.Fatalf(
+			"BACKPACK_STORAGE must be %v or %v, not %v\n",
+			csvBackend,
+			sqliteBackend,
+			backend,
+		)
+	}
+
+	// Finish or discard any transaction interrupted by a crash.
+	if err := replayJournal(dir); err != nil {
+		log.Fatalf("error replaying journal: %v\n", err)
+	}
+
+	// Older versions kept the data of every guild directly in dir.
+	if guildID := os.Getenv("BACKPACK_LEGACY_GUILD"); guildID != "" {
+		if err := migrateLegacyData(dir, guildID); err != nil {
+			log.Fatalf("error migrating data to guild %v: %v\n", guildID, err)
+		}
+	} else if files, err := legacyFiles(dir); err != nil {
+		log.Fatalf("error reading data directory: %v: %v\n", dir, err)
+	} else if len(files) > 0 {
+		log.Println(
+			"found data from an older version which is not used;",
+			"set BACKPACK_LEGACY_GUILD to move it into a guild",
+		)
+	}
+
+	guilds, err := guildDirs(dir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, guild := range guilds {
+		if err := replayJournal(guild); err != nil {
+			log.Fatalf("error replaying journal: %v\n", err)
+		}
+	}
+
 	b := backpack{
-		dir: dir,
+		dir:     dir,
+		backend: backend,
 	}
 
 	// Create a new Discord session using the provided bot token.
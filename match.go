@@ -0,0 +1,131 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the most items offered when a name is ambiguous.
+const maxSuggestions = 5
+
+// matchItem finds the item in recs a user meant by name, which should already
+// be normalized. An exact match is returned as is. Otherwise records are ranked
+// by edit distance and shared words: a single near match is returned as the
+// match, and several possible matches are returned as suggestions, closest
+// first. If nothing is close both are empty.
+func matchItem(name string, recs records) (match string, suggestions []string) {
+	type candidate struct {
+		name     string
+		distance int
+		near     bool
+	}
+
+	var candidates []candidate
+	seen := make(map[string]bool)
+	for _, r := range recs {
+		if r.name == name {
+			return name, nil
+		}
+		if seen[r.name] {
+			continue
+		}
+		seen[r.name] = true
+
+		d := editDistance(name, r.name)
+		near := d <= nearDistance(name)
+		if near || sharesWord(name, r.name) {
+			candidates = append(candidates, candidate{r.name, d, near})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var near []string
+	for _, c := range candidates {
+		if c.near {
+			near = append(near, c.name)
+		}
+		if len(suggestions) < maxSuggestions {
+			suggestions = append(suggestions, c.name)
+		}
+	}
+	if len(near) == 1 {
+		return near[0], nil
+	}
+	return "", suggestions
+}
+
+// didYouMean lists the names of suggested items, given by ID, for the user.
+func didYouMean(suggestions []string, c catalog) string {
+	names := make([]string, len(suggestions))
+	for i, id := range suggestions {
+		names[i] = c.name(id, 1)
+	}
+	return "Did you mean: " + strings.Join(names, ", ") + "?"
+}
+
+// nearDistance is the largest edit distance from name which is still taken as
+// a typo: one edit for every four letters.
+func nearDistance(name string) int {
+	n := len([]rune(name)) / 4
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// sharesWord reports whether a and b have a word in common, or one is a
+// single word found inside the other such as "sword" in "longsword".
+func sharesWord(a, b string) bool {
+	aWords, bWords := strings.Fields(a), strings.Fields(b)
+	for _, aw := range aWords {
+		for _, bw := range bWords {
+			if aw == bw {
+				return true
+			}
+		}
+	}
+	if len(aWords) == 1 && len(aWords[0]) > 2 && strings.Contains(b, aWords[0]) {
+		return true
+	}
+	if len(bWords) == 1 && len(bWords[0]) > 2 && strings.Contains(a, bWords[0]) {
+		return true
+	}
+	return false
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
+
+// min3 returns the smallest of three ints.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,63 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchItem(t *testing.T) {
+	type test struct {
+		name            string
+		recs            []string
+		wantMatch       string
+		wantSuggestions []string
+	}
+
+	tests := []test{
+		{
+			name:      "apple",
+			recs:      []string{"apple", "apples"},
+			wantMatch: "apple",
+		},
+		{
+			name:      "healing potoin",
+			recs:      []string{"healing potion", "mana potion"},
+			wantMatch: "healing potion",
+		},
+		{
+			name:            "potion",
+			recs:            []string{"healing potion", "mana potion", "apple"},
+			wantSuggestions: []string{"mana potion", "healing potion"},
+		},
+		{
+			name:            "sord",
+			recs:            []string{"sword", "lord", "arrow"},
+			wantSuggestions: []string{"sword", "lord"},
+		},
+		{
+			name: "shield",
+			recs: []string{"sword", "arrow"},
+		},
+	}
+
+	for _, tc := range tests {
+		var recs records
+		for _, name := range tc.recs {
+			recs = append(recs, record{count: 1, name: name, price: NotForSale})
+		}
+		match, suggestions := matchItem(tc.name, recs)
+		if match != tc.wantMatch {
+			t.Fatalf("%v: want match %q got %q", tc.name, tc.wantMatch, match)
+		}
+		if !reflect.DeepEqual(suggestions, tc.wantSuggestions) {
+			t.Fatalf(
+				"%v: want suggestions %v got %v",
+				tc.name,
+				tc.wantSuggestions,
+				suggestions,
+			)
+		}
+	}
+}
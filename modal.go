@@ -0,0 +1,110 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// textInputLimit is the most characters discord allows in a text input.
+const textInputLimit = 4000
+
+// modalTitleLimit is the most characters discord allows in a form's title.
+const modalTitleLimit = 45
+
+// bulkModal returns a form asking for a list of items to add to or remove from
+// owner.
+func bulkModal(op, owner string) *discordgo.InteractionResponseData {
+	return &discordgo.InteractionResponseData{
+		CustomID: "bulk:" + op + ":" + owner,
+		Title:    "Bulk " + op,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:    "items",
+						Label:       "Items, one per line",
+						Style:       discordgo.TextInputParagraph,
+						Placeholder: "3 arrows\n1 longsword 15\n50 coins",
+						Required:    true,
+						MaxLength:   textInputLimit,
+					},
+				},
+			},
+		},
+	}
+}
+
+// descriptionModal returns a form for editing the description of the item with
+// id, filled in with its current description.
+func (b backpack) descriptionModal(id string) *discordgo.InteractionResponseData {
+	return &discordgo.InteractionResponseData{
+		CustomID: "describe:set:" + id,
+		Title:    truncate("Describe "+b.catalog.name(id, 1), modalTitleLimit),
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:  "description",
+						Label:     "Description",
+						Style:     discordgo.TextInputParagraph,
+						Value:     b.catalog[id].Description,
+						Required:  false,
+						MaxLength: textInputLimit,
+					},
+				},
+			},
+		},
+	}
+}
+
+// modalHandler is called when one of backpack's forms is submitted. Form IDs
+// are made of a kind, an action, and an ID separated by colons like button
+// IDs.
+func (b backpack) modalHandler(s *discordgo.Session, m *discordgo.InteractionCreate) {
+	data := m.ModalSubmitData()
+	parts := strings.SplitN(data.CustomID, ":", 3)
+	if len(parts) != 3 {
+		return
+	}
+	kind, action, id := parts[0], parts[1], parts[2]
+
+	b, _, a, err := b.prepare(m)
+	if err != nil {
+		whisper(err.Error(), s, m)
+		return
+	}
+
+	switch kind {
+	case "bulk":
+		if !a.canEdit() {
+			whisper(DeniedMessage+" Only gamemasters can "+action+" items.", s, m)
+			return
+		}
+		b.reply(b.bulkModify(modalValue(data, "items"), id, action), id, s, m)
+	case "describe":
+		if !a.canEdit() {
+			whisper(DeniedMessage+" Only gamemasters can describe items.", s, m)
+			return
+		}
+		say(b.setDescription(id, modalValue(data, "description")), s, m)
+	}
+}
+
+// modalValue returns what was written in a submitted form's text input.
+func modalValue(data discordgo.ModalSubmitInteractionData, id string) string {
+	for _, c := range data.Components {
+		row, ok := c.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, c := range row.Components {
+			if input, ok := c.(*discordgo.TextInput); ok && input.CustomID == id {
+				return input.Value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestModalValue(t *testing.T) {
+	raw := `{
+		"custom_id": "describe:set:healing potion",
+		"components": [{
+			"type": 1,
+			"components": [{
+				"type": 4,
+				"custom_id": "description",
+				"value": "Heals 2d4+2.\nTastes of cherries."
+			}]
+		}]
+	}`
+	var data discordgo.ModalSubmitInteractionData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		t.Fatal(err)
+	}
+	want := "Heals 2d4+2.\nTastes of cherries."
+	if got := modalValue(data, "description"); got != want {
+		t.Fatalf("want %q got %q", want, got)
+	}
+	if got := modalValue(data, "items"); got != "" {
+		t.Fatalf("missing input: got %q", got)
+	}
+}
+
+func TestDescriptionModal(t *testing.T) {
+	b := backpack{catalog: catalog{
+		"healing potion": {
+			Name:        "Potion of healing",
+			Description: "Heals 2d4+2.",
+		},
+	}}
+	modal := b.descriptionModal("healing potion")
+	if modal.CustomID != "describe:set:healing potion" {
+		t.Fatalf("incorrect form ID: %v", modal.CustomID)
+	}
+	if modal.Title != "Describe Potion of healing" {
+		t.Fatalf("incorrect title: %v", modal.Title)
+	}
+	input := modal.Components[0].(discordgo.ActionsRow).Components[0].(discordgo.TextInput)
+	if input.Value != "Heals 2d4+2." || input.Style != discordgo.TextInputParagraph {
+		t.Fatalf("incorrect input: %+v", input)
+	}
+
+	long := strings.Repeat("very ", 20) + "long sword"
+	modal = b.descriptionModal(long)
+	if n := len([]rune(modal.Title)); n > modalTitleLimit {
+		t.Fatalf("title is %v characters long", n)
+	}
+}
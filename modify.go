@@ -11,7 +11,7 @@ import (
 //
 // Take note that the transaction can fail due to database corruption or other
 // such issues.
-func (b backpack) modifyItem(count, price int, item, owner, op string) string {
+func (b backpack) modifyItem(count int, price money, item, owner, op string) string {
 	log.Println(owner, op, count, item, price)
 
 	// If we need to set instead of add to the record count.
@@ -24,7 +24,35 @@ func (b backpack) modifyItem(count, price int, item, owner, op string) string {
 		absolute = true
 	}
 
-	name := normalizeName(item)
+	name := b.itemID(item)
+	tx := b.begin(op, owner)
+	defer tx.release()
+
+	// Suggest the items the user may have meant to remove. Removing is
+	// never done on a guess, even a close one, as the wrong item would be
+	// lost.
+	if op == "remove" {
+		recs, err := tx.loadRecords(owner)
+		if err != nil {
+			log.Println(err)
+			return FatalMessage
+		}
+		if !recs.has(name) {
+			match, suggestions := matchItem(name, recs)
+			if match != "" {
+				suggestions = []string{match}
+			}
+			if len(suggestions) > 0 {
+				return fmt.Sprintf(
+					"%v does not have %v to remove\n%v",
+					owner,
+					record{count: -count, name: name, price: Unchanged}.display(b.view()),
+					didYouMean(suggestions, b.catalog),
+				)
+			}
+		}
+	}
+
 	rec := record{
 		count: count,
 		name:  name,
@@ -41,31 +69,47 @@ func (b backpack) modifyItem(count, price int, item, owner, op string) string {
 		name:  rec.name,
 		price: Unchanged,
 	}
-	updated, old, err := updateRecord(rec, b.dir, owner, absolute)
-	if _, ok := err.(*declinedError); ok {
+	updated, old, err := tx.updateRecord(rec, owner, absolute)
+
+	// Only check the owner's load when it grows so the gamemaster can
+	// always lighten it.
+	var warning string
+	if err == nil && updated.count > old.count {
+		var declined bool
+		warning, declined, err = b.checkEncumbrance(tx, owner)
+		if declined {
+			return warning
+		}
+	}
+	if err == nil {
+		err = tx.commit()
+	}
+	if _, ok := err.(*overflowError); ok {
+		return OverflowMessage
+	} else if _, ok := err.(*declinedError); ok {
 		// Declined.
 		response.WriteString(fmt.Sprintf(
 			"%v does not have %v to remove",
 			owner,
-			absNoPriceRec,
+			absNoPriceRec.display(b.view()),
 		))
 	} else if err == nil {
 		switch op {
 		case "set":
 			response.WriteString(fmt.Sprintf(
 				"Set the quantity from %v to %v",
-				old,
-				absNoPriceRec,
+				old.display(b.view()),
+				absNoPriceRec.display(b.view()),
 			))
 		case "add":
 			response.WriteString(fmt.Sprintf(
 				"Added %v",
-				absNoPriceRec,
+				absNoPriceRec.display(b.view()),
 			))
 		case "remove":
 			response.WriteString(fmt.Sprintf(
 				"Removed %v",
-				absNoPriceRec,
+				absNoPriceRec.display(b.view()),
 			))
 		}
 	} else {
@@ -75,6 +119,9 @@ func (b backpack) modifyItem(count, price int, item, owner, op string) string {
 	}
 
 	// Add a little summary line.
-	response.WriteString(fmt.Sprintf("\n%v has %v", owner, updated))
+	response.WriteString(fmt.Sprintf("\n%v has %v", owner, updated.display(b.view())))
+	if warning != "" {
+		response.WriteString("\n" + warning)
+	}
 	return response.String()
 }
@@ -13,7 +13,7 @@ func TestModifyItem(t *testing.T) {
 	type test struct {
 		op    string
 		count int
-		price int
+		price money
 		item  string
 
 		begin string
@@ -103,7 +103,7 @@ func TestModifyItem(t *testing.T) {
 		{
 			op:    "set",
 			count: 0,
-			price: 10,
+			price: 1000,
 			item:  "Mana Potions",
 			begin: "10,Mana Potion,-1",
 			want:  "0,Mana Potion,10",
@@ -116,6 +116,14 @@ func TestModifyItem(t *testing.T) {
 			begin: "10,Mana Potion,-1",
 			want:  "0,Mana Potion,-1",
 		},
+		{
+			op:    "remove",
+			count: 1,
+			item:  "aples",
+			price: -1,
+			begin: "2,apple,-1\n1,arrow,-1",
+			want:  "2,apple,-1\n1,arrow,-1",
+		},
 	}
 
 	for _, tc := range tests {
@@ -149,3 +157,32 @@ func TestModifyItem(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoveNearMatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "owner.csv")
+	if err := os.WriteFile(path, []byte("1,box,-1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	b := backpack{dir: dir}
+	got := b.modifyItem(1, Unchanged, "bow", "owner", "remove")
+	want := "owner does not have 1 Bow to remove\nDid you mean: Box?"
+	if got != want {
+		t.Fatalf("want:\n%v\ngot:\n%v", want, got)
+	}
+
+	// Suggestions use the catalog's names rather than item IDs.
+	b.catalog = catalog{"box": {Name: "Iron chest"}}
+	got = b.modifyItem(1, Unchanged, "bow", "owner", "remove")
+	want = "owner does not have 1 Bow to remove\nDid you mean: Iron chest?"
+	if got != want {
+		t.Fatalf("want:\n%v\ngot:\n%v", want, got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "1,box,-1" {
+		t.Fatalf("removed a near match: %v", string(data))
+	}
+}
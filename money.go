@@ -0,0 +1,155 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// money is a price in hundredths of the least valuable coin. Payments are
+// always made in whole coins.
+type money int64
+
+// coin is the worth of a single coin of the least valuable kind.
+const coin money = 100
+
+// OverflowMessage is sent when a transaction is declined because its numbers
+// are too large to store.
+const OverflowMessage = "That's more than backpack can count! Nothing was changed."
+
+// overflowError declines a change which would make a number too large to
+// store.
+type overflowError struct {
+	r record
+}
+
+func (e *overflowError) Error() string {
+	return fmt.Sprintf("transaction \"%v\" declined: number too large", e.r)
+}
+
+// coins converts a number of the least valuable coin to money. ok is false if
+// it does not fit.
+func coins(n int64) (m money, ok bool) {
+	if n > math.MaxInt64/int64(coin) || n < math.MinInt64/int64(coin) {
+		return 0, false
+	}
+	return money(n) * coin, true
+}
+
+// times returns the price of count items. ok is false if it does not fit.
+func (m money) times(count int) (total money, ok bool) {
+	c := money(count)
+	if m == 0 || c == 0 {
+		return 0, true
+	}
+	total = m * c
+	if total/c != m || (m == -1 && c == math.MinInt64) || (c == -1 && m == math.MinInt64) {
+		return 0, false
+	}
+	return total, true
+}
+
+// ceil returns the number of whole coins needed to pay m. ok is false if it
+// does not fit in an int.
+func (m money) ceil() (n int, ok bool) {
+	whole := int64(m / coin)
+	if m%coin > 0 {
+		whole++
+	}
+	if whole > math.MaxInt || whole < math.MinInt {
+		return 0, false
+	}
+	return int(whole), true
+}
+
+// String writes m as a decimal number of coins such as "25" or "1.50".
+func (m money) String() string {
+	s := strconv.FormatInt(int64(m/coin), 10)
+	if frac := m % coin; frac != 0 {
+		if frac < 0 {
+			frac = -frac
+			if m > -coin {
+				s = "-" + s
+			}
+		}
+		s += fmt.Sprintf(".%02d", frac)
+	}
+	return s
+}
+
+// parseMoney reads a decimal number of coins such as "25" or "1.5". Any
+// precision beyond hundredths is an error.
+func parseMoney(s string) (money, error) {
+	whole, frac, found := strings.Cut(s, ".")
+	neg := strings.HasPrefix(whole, "-")
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %v", s)
+	}
+	m, ok := coins(n)
+	if !ok {
+		return 0, fmt.Errorf("amount too large: %v", s)
+	}
+	if !found {
+		return m, nil
+	}
+	if len(frac) == 0 || len(frac) > 2 || strings.Trim(frac, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid amount: %v", s)
+	}
+	if len(frac) == 1 {
+		frac += "0"
+	}
+	f, _ := strconv.Atoi(frac)
+	if neg {
+		f = -f
+	}
+	sum := m + money(f)
+	if (f > 0 && sum < m) || (f < 0 && sum > m) {
+		return 0, fmt.Errorf("amount too large: %v", s)
+	}
+	return sum, nil
+}
+
+// addCounts adds two counts. ok is false if the sum does not fit.
+func addCounts(a, b int) (sum int, ok bool) {
+	sum = a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, false
+	}
+	return sum, true
+}
+
+// mulCounts multiplies two counts. ok is false if the product does not fit.
+func mulCounts(a, b int) (product int, ok bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product = a * b
+	if product/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, false
+	}
+	return product, true
+}
+
+// formatPrice writes a price to be stored. NotForSale and Unchanged are
+// written as they were before prices had fractions.
+func formatPrice(p money) string {
+	if p == NotForSale || p == Unchanged {
+		return strconv.Itoa(int(p))
+	}
+	return p.String()
+}
+
+// parsePrice reads a price written by formatPrice.
+func parsePrice(s string) (money, error) {
+	switch s {
+	case strconv.Itoa(NotForSale):
+		return NotForSale, nil
+	case strconv.Itoa(Unchanged):
+		return Unchanged, nil
+	}
+	return parseMoney(s)
+}
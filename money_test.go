@@ -0,0 +1,224 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	type test struct {
+		s       string
+		want    money
+		wantErr bool
+	}
+
+	tests := []test{
+		{"25", 2500, false},
+		{"1.5", 150, false},
+		{"1.05", 105, false},
+		{"-0.5", -50, false},
+		{"1.005", 0, true},
+		{"1.", 0, true},
+		{"1.x", 0, true},
+		{"92233720368547759", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, tc := range tests {
+		got, err := parseMoney(tc.s)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("parseMoney(%q): expected an error", tc.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseMoney(%q): %v", tc.s, err)
+		}
+		if got != tc.want {
+			t.Fatalf("parseMoney(%q): want %v got %v", tc.s, tc.want, got)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := map[money]string{
+		2500: "25",
+		150:  "1.50",
+		105:  "1.05",
+		-50:  "-0.50",
+		-150: "-1.50",
+		0:    "0",
+	}
+	for m, want := range tests {
+		if got := m.String(); got != want {
+			t.Fatalf("String(%d): want %q got %q", int64(m), want, got)
+		}
+	}
+}
+
+func TestMoneyTimes(t *testing.T) {
+	if got, ok := money(250).times(3); !ok || got != 750 {
+		t.Fatalf("times: want 750 got %v %v", got, ok)
+	}
+	if _, ok := money(math.MaxInt64 / 2).times(3); ok {
+		t.Fatal("times: expected overflow")
+	}
+	if _, ok := money(math.MinInt64).times(-1); ok {
+		t.Fatal("times: expected overflow")
+	}
+	if got, ok := money(250).ceil(); !ok || got != 3 {
+		t.Fatalf("ceil: want 3 got %v", got)
+	}
+	if got, ok := money(300).ceil(); !ok || got != 3 {
+		t.Fatalf("ceil: want 3 got %v", got)
+	}
+	big := money(math.MaxInt32) * coin * 2
+	if _, ok := big.ceil(); ok != (strconv.IntSize == 64) {
+		t.Fatalf("ceil: %v coins fit in an int: %v", big/coin, ok)
+	}
+}
+
+func TestBuyItemPastInt32(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"buyer.csv":  "2000000000,coin,-1",
+		"seller.csv": "10,gem,1000000000",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 3 gems cost more than fits in a 32 bit int.
+	b := backpack{dir: dir}
+	reply := b.buyItem(3, "gem", "buyer", "seller")
+	if strings.Contains(reply, "bought") {
+		t.Fatalf("bought gems costing more than the buyer has: %v", reply)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(got)) != want {
+			t.Fatalf("%v changed: %v", name, string(got))
+		}
+	}
+}
+
+func TestAddCountOverflow(t *testing.T) {
+	r := record{count: math.MaxInt - 1, name: "apple", price: NotForSale}
+	if err := r.addCount(1); err != nil {
+		t.Fatal(err)
+	}
+	err := r.addCount(1)
+	if _, ok := err.(*overflowError); !ok {
+		t.Fatalf("expected overflowError got %v", err)
+	}
+	if r.count != math.MaxInt {
+		t.Fatalf("count changed after overflow: %v", r.count)
+	}
+}
+
+func TestBuyItemOverflow(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("counts in these inventories need 64 bit ints")
+	}
+	type test struct {
+		count  int
+		buyer  string
+		seller string
+
+		wantReply  string
+		buyerWant  string
+		sellerWant string
+	}
+
+	tests := []test{
+		{
+			// Without checks 2^62 arrows at $4 costs nothing.
+			count:      math.MaxInt/2 + 1,
+			buyer:      "5,coin,-1",
+			seller:     "9223372036854775807,arrow,4",
+			wantReply:  OverflowMessage,
+			buyerWant:  "5,coin,-1",
+			sellerWant: "9223372036854775807,arrow,4",
+		},
+		{
+			count:      1,
+			buyer:      "9223372036854775807,arrow,-1\n5,coin,-1",
+			seller:     "1,arrow,1",
+			wantReply:  OverflowMessage,
+			buyerWant:  "9223372036854775807,arrow,-1\n5,coin,-1",
+			sellerWant: "1,arrow,1",
+		},
+		{
+			count:  3,
+			buyer:  "5,coin,-1",
+			seller: "10,arrow,0.25",
+			wantReply: "buyer bought 3 Arrows for $1\n" +
+				"buyer has 3 Arrows\n" +
+				"seller has 7 Arrows for sale for $0.25",
+			buyerWant:  "4,coin,-1\n3,arrow,-1",
+			sellerWant: "7,arrow,0.25\n1,coin,-1",
+		},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		buyerPath := filepath.Join(dir, "buyer.csv")
+		err := os.WriteFile(buyerPath, []byte(tc.buyer), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sellerPath := filepath.Join(dir, "seller.csv")
+		err = os.WriteFile(sellerPath, []byte(tc.seller), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := backpack{
+			dir: dir,
+		}
+		reply := b.buyItem(tc.count, "arrows", "buyer", "seller")
+		if tc.wantReply != reply {
+			t.Fatalf(
+				"incorrect reply:\nwant:\n%v\ngot:\n%v\n",
+				tc.wantReply,
+				reply,
+			)
+		}
+
+		buyerGot, err := os.ReadFile(buyerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(buyerGot)) != tc.buyerWant {
+			t.Fatalf(
+				"incorrect buyer inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.buyerWant,
+				string(buyerGot),
+			)
+		}
+		sellerGot, err := os.ReadFile(sellerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(sellerGot)) != tc.sellerWant {
+			t.Fatalf(
+				"incorrect seller inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.sellerWant,
+				string(sellerGot),
+			)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// mentionPattern matches channel, user, and role mentions.
+var mentionPattern = regexp.MustCompile(`^<(#|@!?|@&)([0-9]+)>$`)
+
+// namePattern matches the names which may be registered as named owners.
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9 _-]{0,31}$`)
+
+// ownerError explains to the user why an owner was rejected.
+type ownerError struct {
+	owner string
+	named []string
+}
+
+func (e *ownerError) Error() string {
+	msg := fmt.Sprintf(
+		"\"%v\" is not an inventory. Use a #channel, @user, or @role",
+		e.owner,
+	)
+	if len(e.named) == 0 {
+		return msg + "."
+	}
+	return msg + " or one of: " + strings.Join(e.named, ", ") + "."
+}
+
+// resolveOwner turns an owner given by a user into the key its inventory is
+// stored under. Only channel, user, and role mentions or the named owners in
+// st are accepted. Mentions are converted to a single canonical form so the
+// same user always maps to the same inventory.
+func resolveOwner(owner string, st settings) (string, error) {
+	owner = strings.TrimSpace(owner)
+	if m := mentionPattern.FindStringSubmatch(owner); m != nil {
+		kind := m[1]
+		if kind == "@!" {
+			kind = "@"
+		}
+		return "<" + kind + m[2] + ">", nil
+	}
+
+	name := strings.ToLower(owner)
+	if contains(st.Owners, name) {
+		return name, nil
+	}
+	return "", &ownerError{owner: owner, named: st.Owners}
+}
+
+// registerOwner adds a named owner so it may be used as an inventory in
+// addition to channels, users, and roles.
+func (b backpack) registerOwner(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !namePattern.MatchString(name) {
+		return "Owner names must be 1 to 32 letters, numbers, spaces, " +
+			"dashes, or underscores."
+	}
+
+	errRegistered := errors.New("already registered")
+	err := b.updateSettings(func(st *settings) error {
+		if contains(st.Owners, name) {
+			return errRegistered
+		}
+		st.Owners = append(st.Owners, name)
+		return nil
+	})
+	if err == errRegistered {
+		return name + " is already registered."
+	} else if err != nil {
+		log.Printf("error registering owner %v: %v\n", name, err)
+		return FatalMessage
+	}
+	return "Registered " + name + "."
+}
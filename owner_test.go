@@ -0,0 +1,74 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import "testing"
+
+func TestResolveOwner(t *testing.T) {
+	type test struct {
+		owner string
+		want  string
+		ok    bool
+	}
+
+	st := settings{Owners: []string{"shop", "party loot"}}
+	tests := []test{
+		{owner: "<#123>", want: "<#123>", ok: true},
+		{owner: " <#123> ", want: "<#123>", ok: true},
+		{owner: "<@123>", want: "<@123>", ok: true},
+		{owner: "<@!123>", want: "<@123>", ok: true},
+		{owner: "<@&123>", want: "<@&123>", ok: true},
+		{owner: "shop", want: "shop", ok: true},
+		{owner: "Party Loot", want: "party loot", ok: true},
+		{owner: "tavern"},
+		{owner: "../../etc/passwd"},
+		{owner: "<#123>/../x"},
+		{owner: "<#abc>"},
+		{owner: ""},
+	}
+
+	for _, tc := range tests {
+		got, err := resolveOwner(tc.owner, st)
+		if tc.ok && err != nil {
+			t.Fatalf("%q: %v", tc.owner, err)
+		}
+		if !tc.ok && err == nil {
+			t.Fatalf("%q: accepted as %q", tc.owner, got)
+		}
+		if got != tc.want {
+			t.Fatalf("%q: want: %q got: %q", tc.owner, tc.want, got)
+		}
+	}
+}
+
+func TestRegisterOwner(t *testing.T) {
+	b := backpack{dir: t.TempDir()}
+
+	type test struct {
+		name string
+		want string
+	}
+	tests := []test{
+		{name: "Shop", want: "Registered shop."},
+		{name: "shop", want: "shop is already registered."},
+		{
+			name: "../shop",
+			want: "Owner names must be 1 to 32 letters, numbers, spaces, " +
+				"dashes, or underscores.",
+		},
+	}
+	for _, tc := range tests {
+		got := b.registerOwner(tc.name)
+		if got != tc.want {
+			t.Fatalf("want: %v got: %v\n", tc.want, got)
+		}
+	}
+
+	st, err := b.loadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveOwner("shop", st); err != nil {
+		t.Fatal(err)
+	}
+}
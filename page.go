@@ -0,0 +1,228 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// messageLimit is the most characters discord allows in a message.
+const messageLimit = 2000
+
+// pageLimit is the most characters of tables put on a page, leaving room for
+// the weight and page number under them.
+const pageLimit = messageLimit - 200
+
+// customIDLimit is the most characters discord allows in a button's ID.
+const customIDLimit = 100
+
+// pages splits the records into tables which each fit in limit characters.
+// Every table has the same columns and shows the total worth of all the
+// records.
+func (rs records) pages(v view, limit int) []string {
+	head, body, foot := rs.rows(v, v.currency.wealth(rs))
+	width := lipgloss.Width(head[0])
+	// Rows are padded to the width of the table and put between borders.
+	size := func(row string) int {
+		return len(row) + width - lipgloss.Width(row) + len("║║\n")
+	}
+	// The top and bottom borders and the backticks around them.
+	fixed := 2*(width*len("═")+len("╔╗\n")) + len("```\n\n```")
+	for _, row := range append(head, foot...) {
+		fixed += size(row)
+	}
+
+	var pages []string
+	start, total := 0, fixed
+	for i, row := range body {
+		if i > start && total+size(row) > limit {
+			pages = append(pages, box(head, body[start:i], foot))
+			start, total = i, fixed
+		}
+		total += size(row)
+	}
+	return append(pages, box(head, body[start:], foot))
+}
+
+// pack joins blocks of text into as few pages as possible without any page
+// going over limit characters. Blocks are never split.
+func pack(blocks []string, limit int) []string {
+	var pages []string
+	var page string
+	for _, block := range blocks {
+		if page != "" && len(page)+len("\n")+len(block) > limit {
+			pages = append(pages, page)
+			page = ""
+		}
+		if page != "" {
+			page += "\n"
+		}
+		page += block
+	}
+	return append(pages, page)
+}
+
+// viewQuery is what a view shows, so it can be shown again when changing
+// pages.
+type viewQuery struct {
+	owner  string
+	filter string
+	group  string
+	order  string
+}
+
+// encode writes the query so it fits in a button's ID.
+func (q viewQuery) encode() string {
+	values := make(url.Values)
+	for key, value := range map[string]string{
+		"o": q.owner,
+		"f": q.filter,
+		"g": q.group,
+		"s": q.order,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values.Encode()
+}
+
+// decodeViewQuery reads a query written by encode.
+func decodeViewQuery(s string) (viewQuery, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return viewQuery{}, fmt.Errorf("failed decoding view %v: %v", s, err)
+	}
+	return viewQuery{
+		owner:  values.Get("o"),
+		filter: values.Get("f"),
+		group:  values.Get("g"),
+		order:  values.Get("s"),
+	}, nil
+}
+
+// viewPage returns a page of the query's inventory, counting from 1, along
+// with buttons to move to the previous and next pages. Pages out of range are
+// moved into it. Inventories are shown in the guild's chosen style, and label
+// names the owner in embeds.
+func (b backpack) viewPage(
+	q viewQuery,
+	page int,
+	label func(string) string,
+) *discordgo.InteractionResponseData {
+	inv, msg := b.loadInventory(q)
+	if msg != "" {
+		return &discordgo.InteractionResponseData{
+			Content:    msg,
+			Components: []discordgo.MessageComponent{},
+		}
+	}
+
+	if b.render == renderEmbed {
+		embeds := b.inventoryEmbeds(inv, label)
+		page = clamp(page, len(embeds))
+		embed := embeds[page-1]
+		buttons := pageButtons(q, page, len(embeds))
+		if len(embeds) > 1 {
+			text := pageNumber(page, len(embeds), buttons)
+			if embed.Footer != nil {
+				text = embed.Footer.Text + " · " + text
+			}
+			embed.Footer = &discordgo.MessageEmbedFooter{Text: text}
+		}
+		return &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: buttons,
+		}
+	}
+
+	pages := b.tablePages(inv)
+	page = clamp(page, len(pages))
+	content := pages[page-1]
+	buttons := pageButtons(q, page, len(pages))
+	if len(pages) > 1 {
+		content += "\n" + pageNumber(page, len(pages), buttons)
+	}
+	return &discordgo.InteractionResponseData{
+		Content:    content,
+		Components: buttons,
+	}
+}
+
+// pageNumber says which page of a view is shown. If the view has no buttons
+// the user is told how to see the rest.
+func pageNumber(page, pages int, buttons []discordgo.MessageComponent) string {
+	text := fmt.Sprintf("Page %v of %v", page, pages)
+	if len(buttons) == 0 {
+		text += ". This view is too long to page through, " +
+			"use a shorter filter to see the rest."
+	}
+	return text
+}
+
+// clamp moves page into the range of pages, counting from 1.
+func clamp(page, pages int) int {
+	if page > pages {
+		page = pages
+	}
+	if page < 1 {
+		page = 1
+	}
+	return page
+}
+
+// pageButtons returns buttons to move to the previous and next pages of a
+// view. A view with only one page has no buttons.
+func pageButtons(q viewQuery, page, pages int) []discordgo.MessageComponent {
+	if pages <= 1 {
+		return []discordgo.MessageComponent{}
+	}
+	id := q.encode()
+	prev := "view:" + strconv.Itoa(page-1) + ":" + id
+	next := "view:" + strconv.Itoa(page+1) + ":" + id
+	if len(prev) > customIDLimit || len(next) > customIDLimit {
+		// The view can't be remembered by the buttons so only the first
+		// page is shown.
+		return []discordgo.MessageComponent{}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+					CustomID: prev,
+					Disabled: page == 1,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					CustomID: next,
+					Disabled: page == pages,
+				},
+			},
+		},
+	}
+}
+
+// showPage replies with a page of an inventory and its buttons. If the
+// interaction is a pressed button the message is replaced instead.
+func showPage(
+	data *discordgo.InteractionResponseData,
+	s *discordgo.Session,
+	m *discordgo.InteractionCreate,
+) {
+	kind := discordgo.InteractionResponseChannelMessageWithSource
+	if m.Type == discordgo.InteractionMessageComponent {
+		kind = discordgo.InteractionResponseUpdateMessage
+	}
+	s.InteractionRespond(m.Interaction, &discordgo.InteractionResponse{
+		Type: kind,
+		Data: data,
+	})
+}
@@ -0,0 +1,151 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestRecordsPages(t *testing.T) {
+	var recs records
+	for i := 0; i < 200; i++ {
+		recs = append(recs, record{
+			count: i + 1,
+			name:  fmt.Sprintf("item %03d", i),
+			price: NotForSale,
+		})
+	}
+	pages := recs.pages(view{}, pageLimit)
+	if len(pages) < 2 {
+		t.Fatalf("want several pages got %v", len(pages))
+	}
+	var all string
+	for _, page := range pages {
+		if len(page) > pageLimit {
+			t.Fatalf("page is %v characters long:\n%v", len(page), page)
+		}
+		if !strings.HasPrefix(page, "```\n╔") || !strings.HasSuffix(page, "╝\n```") {
+			t.Fatalf("page is not a table:\n%v", page)
+		}
+		if !strings.Contains(page, "Quantity") {
+			t.Fatalf("page is missing its header:\n%v", page)
+		}
+		all += page
+	}
+	for _, r := range recs {
+		if n := strings.Count(all, displayName(r.name, r.count)+" "); n != 1 {
+			t.Fatalf("%v is shown %v times", r.name, n)
+		}
+	}
+
+	pages = records{}.pages(view{}, pageLimit)
+	if len(pages) != 1 {
+		t.Fatalf("empty records want 1 page got %v", len(pages))
+	}
+}
+
+func TestPack(t *testing.T) {
+	blocks := []string{"aaaa", "bbbb", "cccc", "dddddddddddd"}
+	want := []string{"aaaa\nbbbb", "cccc", "dddddddddddd"}
+	if got := pack(blocks, 10); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %q got %q", want, got)
+	}
+}
+
+func TestViewQuery(t *testing.T) {
+	queries := []viewQuery{
+		{owner: "<#123>"},
+		{owner: "the party & friends", filter: "very rare", group: groupRarity, order: sortPrice},
+	}
+	for _, q := range queries {
+		got, err := decodeViewQuery(q.encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != q {
+			t.Fatalf("want %v got %v", q, got)
+		}
+	}
+}
+
+func TestViewPage(t *testing.T) {
+	dir := t.TempDir()
+	var inventory bytes.Buffer
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&inventory, "%v,item %03d,-1\n", i+1, i)
+	}
+	err := os.WriteFile(filepath.Join(dir, "shop.csv"), inventory.Bytes(), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backpack{dir: dir}
+	q := viewQuery{owner: "shop"}
+
+	buttons := func(components []discordgo.MessageComponent) []discordgo.Button {
+		if len(components) != 1 {
+			t.Fatalf("want 1 row of buttons got %v", components)
+		}
+		var bs []discordgo.Button
+		for _, c := range components[0].(discordgo.ActionsRow).Components {
+			bs = append(bs, c.(discordgo.Button))
+		}
+		return bs
+	}
+
+	label := func(owner string) string { return owner }
+	data := b.viewPage(q, 1, label)
+	content, components := data.Content, data.Components
+	pages := len(b.inventoryPages("shop", "", "", ""))
+	if !strings.HasSuffix(content, fmt.Sprintf("Page 1 of %v", pages)) {
+		t.Fatalf("missing page number:\n%v", content)
+	}
+	if len(content) > messageLimit {
+		t.Fatalf("page is %v characters long", len(content))
+	}
+	bs := buttons(components)
+	if !bs[0].Disabled || bs[1].Disabled {
+		t.Fatalf("first page buttons: %+v", bs)
+	}
+	if bs[1].CustomID != "view:2:o=shop" {
+		t.Fatalf("incorrect next button: %v", bs[1].CustomID)
+	}
+
+	data = b.viewPage(q, pages+5, label)
+	content, components = data.Content, data.Components
+	if !strings.HasSuffix(content, fmt.Sprintf("Page %v of %v", pages, pages)) {
+		t.Fatalf("page out of range not moved to last page:\n%v", content)
+	}
+	bs = buttons(components)
+	if bs[0].Disabled || !bs[1].Disabled {
+		t.Fatalf("last page buttons: %+v", bs)
+	}
+
+	data = b.viewPage(viewQuery{owner: "empty"}, 1, label)
+	content, components = data.Content, data.Components
+	if strings.Contains(content, "Page") || len(components) != 0 {
+		t.Fatalf("single page has page controls:\n%v", content)
+	}
+
+	// An owner too long to fit in a button's ID can't be paged.
+	long := strings.Repeat("s", customIDLimit)
+	err = os.WriteFile(filepath.Join(dir, long+".csv"), inventory.Bytes(), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = b.viewPage(viewQuery{owner: long}, 1, label)
+	content, components = data.Content, data.Components
+	if len(components) != 0 {
+		t.Fatalf("buttons past the ID limit: %+v", components)
+	}
+	if !strings.Contains(content, "too long to page") {
+		t.Fatalf("missing paging note:\n%v", content)
+	}
+}
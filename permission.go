@@ -0,0 +1,174 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DeniedMessage is sent to users who try to do something their roles do not
+// allow.
+const DeniedMessage = "You don't have permission to do that."
+
+// level is the amount of access a member has to a guild's inventories.
+type level int
+
+const (
+	// noAccess members may not use backpack.
+	noAccess level = iota
+
+	// player members may view inventories and spend from their own.
+	player
+
+	// gamemaster members may change any inventory.
+	gamemaster
+)
+
+func (l level) String() string {
+	switch l {
+	case player:
+		return "player"
+	case gamemaster:
+		return "gamemaster"
+	}
+	return "none"
+}
+
+// access describes what the member running a command may do.
+type access struct {
+	level level
+
+	// admin members may manage the guild and so configure backpack.
+	admin bool
+
+	// own are the inventories which belong to the member: their own and
+	// those of their roles.
+	own map[string]bool
+
+	// self is the member's own inventory, if they are known.
+	self string
+}
+
+// memberAccess works out the access of the member running a command from
+// their roles and permissions. Members who can manage the guild are always
+// gamemasters. Channel inventories, such as shops, belong to nobody so only
+// gamemasters may spend from them.
+func memberAccess(member *discordgo.Member, st settings) access {
+	a := access{own: make(map[string]bool)}
+	if member == nil {
+		return a
+	}
+	if member.User != nil {
+		a.self = "<@" + member.User.ID + ">"
+		a.own[a.self] = true
+	}
+	for _, role := range member.Roles {
+		a.own["<@&"+role+">"] = true
+	}
+
+	const manage = discordgo.PermissionAdministrator |
+		discordgo.PermissionManageServer
+	if member.Permissions&manage != 0 {
+		a.admin = true
+		a.level = gamemaster
+		return a
+	}
+	if len(st.Players) == 0 {
+		a.level = player
+	}
+	for _, role := range member.Roles {
+		if contains(st.Gamemasters, role) {
+			a.level = gamemaster
+			return a
+		}
+		if contains(st.Players, role) {
+			a.level = player
+		}
+	}
+	return a
+}
+
+// payer returns the inventory the member pays from when they don't name one.
+// Players pay from their own inventory and gamemasters from channel, the one
+// the command was sent in.
+func (a access) payer(channel string) string {
+	if a.level == player && a.self != "" {
+		return a.self
+	}
+	return channel
+}
+
+// canView reports whether the member may look at inventories.
+func (a access) canView() bool {
+	return a.level >= player
+}
+
+// canSpend reports whether the member may take items or coins from owner's
+// inventory.
+func (a access) canSpend(owner string) bool {
+	return a.level == gamemaster || (a.level == player && a.own[owner])
+}
+
+// canEdit reports whether the member may freely change inventories and
+// descriptions.
+func (a access) canEdit() bool {
+	return a.level == gamemaster
+}
+
+// canAccept reports whether the member may accept or decline a trade offer.
+func (a access) canAccept(t trade) bool {
+	return a.canSpend(t.To)
+}
+
+// setRoleLevel gives members with the role the level of access.
+func (b backpack) setRoleLevel(role, name string) string {
+	m := mentionPattern.FindStringSubmatch(role)
+	if m == nil || m[1] != "@&" {
+		return "Please mention a role such as @players."
+	}
+	id := m[2]
+
+	err := b.updateSettings(func(st *settings) error {
+		st.Gamemasters = remove(st.Gamemasters, id)
+		st.Players = remove(st.Players, id)
+		switch name {
+		case gamemaster.String():
+			st.Gamemasters = append(st.Gamemasters, id)
+		case player.String():
+			st.Players = append(st.Players, id)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("error setting role %v to %v: %v\n", id, name, err)
+		return FatalMessage
+	}
+	if name != gamemaster.String() && name != player.String() {
+		return fmt.Sprintf("<@&%v> no longer has a level.", id)
+	}
+	return fmt.Sprintf("<@&%v> is now a %v.", id, name)
+}
+
+// contains reports whether s is in list.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// remove returns list without s.
+func remove(list []string, s string) []string {
+	var out []string
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
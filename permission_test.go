@@ -0,0 +1,155 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMemberAccess(t *testing.T) {
+	type test struct {
+		member *discordgo.Member
+		st     settings
+
+		level level
+		admin bool
+	}
+
+	st := settings{
+		Gamemasters: []string{"gm"},
+		Players:     []string{"pc"},
+	}
+	tests := []test{
+		{
+			member: &discordgo.Member{Roles: []string{"pc", "gm"}},
+			st:     st,
+			level:  gamemaster,
+		},
+		{
+			member: &discordgo.Member{Roles: []string{"pc"}},
+			st:     st,
+			level:  player,
+		},
+		{
+			member: &discordgo.Member{Roles: []string{"npc"}},
+			st:     st,
+			level:  noAccess,
+		},
+		{
+			member: &discordgo.Member{Roles: []string{"npc"}},
+			st:     settings{Gamemasters: []string{"gm"}},
+			level:  player,
+		},
+		{
+			member: &discordgo.Member{
+				Permissions: discordgo.PermissionManageServer,
+			},
+			st:    st,
+			level: gamemaster,
+			admin: true,
+		},
+	}
+	for _, tc := range tests {
+		a := memberAccess(tc.member, tc.st)
+		if a.level != tc.level || a.admin != tc.admin {
+			t.Fatalf(
+				"%v: want: %v %v got: %v %v\n",
+				tc.member.Roles,
+				tc.level, tc.admin,
+				a.level, a.admin,
+			)
+		}
+	}
+}
+
+func TestCanSpend(t *testing.T) {
+	member := &discordgo.Member{
+		User:  &discordgo.User{ID: "2"},
+		Roles: []string{"pc"},
+	}
+	a := memberAccess(member, settings{Players: []string{"pc"}})
+	for _, owner := range []string{"<@2>", "<@&pc>"} {
+		if !a.canSpend(owner) {
+			t.Fatalf("player can't spend from own inventory %v", owner)
+		}
+	}
+	for _, owner := range []string{"<#1>", "<#3>", "<@3>", "shop"} {
+		if a.canSpend(owner) {
+			t.Fatalf("player can spend from %v", owner)
+		}
+	}
+	if a.canEdit() {
+		t.Fatal("player can edit")
+	}
+}
+
+func TestChannelNotOwned(t *testing.T) {
+	b := backpack{dir: t.TempDir()}
+	m := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		GuildID:   "1",
+		ChannelID: "2",
+		Member: &discordgo.Member{
+			User:  &discordgo.User{ID: "3"},
+			Roles: []string{"pc"},
+		},
+	}}
+	_, _, a, err := b.prepare(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.level != player {
+		t.Fatalf("want player got %v", a.level)
+	}
+	if a.canSpend("<#2>") {
+		t.Fatal("player can spend from the channel the command was sent in")
+	}
+	if got := a.payer("<#2>"); got != "<@3>" {
+		t.Fatalf("player pays from %v instead of their own inventory", got)
+	}
+	gm := access{level: gamemaster, self: "<@4>"}
+	if got := gm.payer("<#2>"); got != "<#2>" {
+		t.Fatalf("gamemaster pays from %v instead of the channel", got)
+	}
+}
+
+func TestCanAccept(t *testing.T) {
+	member := &discordgo.Member{
+		User:  &discordgo.User{ID: "2"},
+		Roles: []string{"pc"},
+	}
+	a := memberAccess(member, settings{Players: []string{"pc"}})
+	if !a.canAccept(trade{Proposer: "3", To: "<@2>"}) {
+		t.Fatal("player can't accept a trade offered to them")
+	}
+	if a.canAccept(trade{Proposer: "3", To: "<#4>"}) {
+		t.Fatal("player can accept a trade offered to a channel")
+	}
+	if a.canAccept(trade{Proposer: "3", To: "<@5>"}) {
+		t.Fatal("player can accept a trade offered to someone else")
+	}
+}
+
+func TestSetRoleLevel(t *testing.T) {
+	b := backpack{dir: t.TempDir()}
+	if got := b.setRoleLevel("gm", "gamemaster"); got != "Please mention a role such as @players." {
+		t.Fatalf("accepted a role which isn't a mention: %v", got)
+	}
+	b.setRoleLevel("<@&1>", "player")
+	b.setRoleLevel("<@&1>", "gamemaster")
+	b.setRoleLevel("<@&2>", "player")
+	b.setRoleLevel("<@&3>", "player")
+	b.setRoleLevel("<@&3>", "none")
+
+	st, err := b.loadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Gamemasters) != 1 || st.Gamemasters[0] != "1" {
+		t.Fatalf("want gamemasters [1] got: %v", st.Gamemasters)
+	}
+	if len(st.Players) != 1 || st.Players[0] != "2" {
+		t.Fatalf("want players [2] got: %v", st.Players)
+	}
+}
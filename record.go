@@ -31,13 +31,21 @@ func (e *declinedError) Error() string {
 type record struct {
 	count int
 	name  string
-	price int
+	price money
+
+	// offer is the price paid when buying the item back from a seller. Zero
+	// means the item is not bought back.
+	offer money
 }
 
 // addCount adds to a record's count.
-// If the count would be made negative, a declinedError is returned.
+// If the count would be made negative, a declinedError is returned. If it would
+// be too large, an overflowError is returned.
 func (r *record) addCount(count int) error {
-	sum := r.count + count
+	sum, ok := addCounts(r.count, count)
+	if !ok {
+		return &overflowError{*r}
+	}
 	if sum < 0 {
 		return &declinedError{*r}
 	}
@@ -47,16 +55,26 @@ func (r *record) addCount(count int) error {
 
 // String prints out a pretty message describing the record.
 func (r record) String() string {
+	return r.display(view{})
+}
+
+// display describes the record like String, using the names and currency of
+// v.
+func (r record) display(v view) string {
 	var buf bytes.Buffer
 
 	// Ignoring error to use 0 as fallback count.
 	buf.WriteString(strconv.Itoa(r.count))
 	buf.WriteString(" ")
-	buf.WriteString(displayName(r.name, r.count))
+	buf.WriteString(v.catalog.name(r.name, r.count))
 
 	if r.price != NotForSale && r.price != Unchanged {
-		buf.WriteString(" for sale for $")
-		buf.WriteString(humanize.Comma(int64(r.price)))
+		buf.WriteString(" for sale for ")
+		buf.WriteString(v.currency.format(r.price))
+	}
+	if r.offer > 0 {
+		buf.WriteString(" bought for ")
+		buf.WriteString(v.currency.format(r.offer))
 	}
 
 	return buf.String()
@@ -82,15 +100,31 @@ var recordsColumn = lipgloss.NewStyle().
 //	║ 1         Death Potion    $5,000 ║
 //	╚══════════════════════════════════╝
 //
-// The price column is omitted if no items contain a price.
+// The price column is omitted if no items contain a price. An offer column with
+// buy-back prices is added after it if any items are bought back.
 func (rs records) String() string {
-	var buf bytes.Buffer
-	buf.WriteString("```\n")
+	return rs.table(view{})
+}
+
+// table prints the records like String, using the names and currency of v. If
+// the currency has denominations and the records hold any coins, their total
+// worth is shown under the table.
+func (rs records) table(v view) string {
+	head, body, foot := rs.rows(v, v.currency.wealth(rs))
+	return box(head, body, foot)
+}
+
+// rows lays out the records like table without the box around them. head is
+// the header and the line under it, body has a row for each record, and foot
+// shows wealth as the total worth if there is one.
+func (rs records) rows(v view, wealth int) (head, body, foot []string) {
+	c := v.currency
 
 	// Gather column data.
 	var counts []string
 	var names []string
 	var prices []string
+	var offers []string
 	for _, r := range rs {
 		if r.count == 0 {
 			// Skip records with 0 count.
@@ -100,14 +134,20 @@ func (rs records) String() string {
 
 		names = append(
 			names,
-			strings.TrimSpace(displayName(r.name, r.count)),
+			strings.TrimSpace(v.catalog.name(r.name, r.count)),
 		)
 
 		if r.price != NotForSale {
-			prices = append(prices, "$"+humanize.Comma(int64(r.price)))
+			prices = append(prices, c.format(r.price))
 		} else {
 			prices = append(prices, "")
 		}
+
+		if r.offer > 0 {
+			offers = append(offers, c.format(r.offer))
+		} else {
+			offers = append(offers, "")
+		}
 	}
 
 	// Add headings.
@@ -126,6 +166,17 @@ func (rs records) String() string {
 	} else {
 		prices = []string{}
 	}
+	found = false
+	for _, o := range offers {
+		if o != "" {
+			found = true
+		}
+	}
+	if found {
+		offers = append([]string{"Offer"}, offers...)
+	} else {
+		offers = []string{}
+	}
 
 	countCol := recordsColumn.Render(
 		lipgloss.JoinVertical(lipgloss.Top, counts...),
@@ -140,21 +191,52 @@ func (rs records) String() string {
 	if lipgloss.Height(priceCol) <= 1 {
 		priceCol = ""
 	}
+	offerCol := recordsColumn.Render(
+		lipgloss.JoinVertical(lipgloss.Top, offers...),
+	)
+	if lipgloss.Height(offerCol) <= 1 {
+		offerCol = ""
+	}
 
-	table := lipgloss.JoinHorizontal(lipgloss.Left, countCol, nameCol, priceCol)
+	table := lipgloss.JoinHorizontal(
+		lipgloss.Left,
+		countCol,
+		nameCol,
+		priceCol,
+		offerCol,
+	)
 	// Add a line under the header.
 	line := strings.Repeat("─", lipgloss.Width(table))
 	rows := strings.Split(table, "\n")
-	header := rows[0]
-	body := rows[1:]
-	rows = append([]string{header, line}, body...)
-	table = strings.Join(rows, "\n")
+	head = []string{rows[0], line}
+	body = rows[1:]
+	if len(c) > 0 && wealth > 0 {
+		foot = []string{line, " Total: " + c.formatCoins(wealth)}
+	}
+	return head, body, foot
+}
 
-	buf.WriteString(recordsTable.Render(table))
+// box draws the border around rows of a table and wraps it in 3 backticks for
+// discord.
+func box(head, body, foot []string) string {
+	var buf bytes.Buffer
+	buf.WriteString("```\n")
+	rows := append(append(append([]string(nil), head...), body...), foot...)
+	buf.WriteString(recordsTable.Render(strings.Join(rows, "\n")))
 	buf.WriteString("\n```")
 	return buf.String()
 }
 
+// has reports whether there is a record for the named item.
+func (rs records) has(name string) bool {
+	for _, r := range rs {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // forSale returns records which have a price.
 func (rs records) forSale() records {
 	var recs records
@@ -166,3 +248,15 @@ func (rs records) forSale() records {
 	}
 	return recs
 }
+
+// buying returns records which are bought back.
+func (rs records) buying() records {
+	var recs records
+	for _, r := range rs {
+		if r.offer <= 0 {
+			continue
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
@@ -13,7 +13,7 @@ func TestRecordString(t *testing.T) {
 			r: record{
 				count: 1,
 				name:  "apple",
-				price: 5,
+				price: 500,
 			},
 			want: "1 Apple for sale for $5",
 		},
@@ -21,7 +21,7 @@ func TestRecordString(t *testing.T) {
 			r: record{
 				count: 0,
 				name:  "apple",
-				price: 5,
+				price: 500,
 			},
 			want: "0 Apples for sale for $5",
 		},
@@ -29,7 +29,7 @@ func TestRecordString(t *testing.T) {
 			r: record{
 				count: 10,
 				name:  "apple",
-				price: 5,
+				price: 500,
 			},
 			want: "10 Apples for sale for $5",
 		},
@@ -49,6 +49,15 @@ func TestRecordString(t *testing.T) {
 			},
 			want: "10 Apples",
 		},
+		{
+			r: record{
+				count: 2,
+				name:  "longsword",
+				price: 1500,
+				offer: 800,
+			},
+			want: "2 Longswords for sale for $15 bought for $8",
+		},
 	}
 	for _, tc := range tests {
 		got := tc.r.String()
@@ -70,17 +79,17 @@ func TestRecordsString(t *testing.T) {
 				{
 					count: 10,
 					name:  "Health Potion",
-					price: 10,
+					price: 1000,
 				},
 				{
 					count: 10000,
 					name:  "Mana Potion",
-					price: 8,
+					price: 800,
 				},
 				{
 					count: 1,
 					name:  "Death Potion",
-					price: 5000,
+					price: 500000,
 				},
 			},
 			want: "`" + `` + "`" + `` + "`" + `
@@ -131,7 +140,7 @@ func TestRecordsString(t *testing.T) {
 				{
 					count: 10000,
 					name:  "Mana Potion",
-					price: 8,
+					price: 800,
 				},
 				{
 					count: 1,
@@ -158,7 +167,7 @@ func TestRecordsString(t *testing.T) {
 				{
 					count: 10000,
 					name:  "Mana Potion",
-					price: 8,
+					price: 800,
 				},
 				{
 					count: 1,
@@ -180,7 +189,7 @@ func TestRecordsString(t *testing.T) {
 				{
 					count: 1,
 					name:  "apple",
-					price: 1,
+					price: 100,
 				},
 			},
 			want: "`" + `` + "`" + `` + "`" + `
@@ -205,6 +214,29 @@ func TestRecordsString(t *testing.T) {
 ║─────────────────║
 ║ 1         Apple ║
 ╚═════════════════╝
+` + "`" + `` + "`" + `` + "`",
+		},
+		{
+			rs: records{
+				{
+					count: 2,
+					name:  "longsword",
+					price: 1500,
+					offer: 800,
+				},
+				{
+					count: 1,
+					name:  "apple",
+					price: -1,
+				},
+			},
+			want: "`" + `` + "`" + `` + "`" + `
+╔════════════════════════════════════╗
+║ Quantity  Item        Price  Offer ║
+║────────────────────────────────────║
+║ 2         Longswords  $15    $8    ║
+║ 1         Apple                    ║
+╚════════════════════════════════════╝
 ` + "`" + `` + "`" + `` + "`",
 		},
 	}
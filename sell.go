@@ -0,0 +1,272 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// sellItem removes an item from the seller, pays the seller the merchant's
+// buy-back price in coins, and then adds the item to the merchant. The
+// merchant's budget, if it has one, is reduced by the payment.
+//
+// Undoing a sale returns the items and coins, but not the budget, which a
+// gamemaster may set again.
+func (b backpack) sellItem(count int, item, seller, merchant string) string {
+	log.Println(seller, "sold", count, item, "to", merchant)
+
+	if seller == merchant {
+		return fmt.Sprintf(
+			"bruh. alright...\n%v sold %v %v to %v",
+			seller,
+			count, item,
+			merchant,
+		)
+	}
+
+	// Invalid items.
+	if item == "" {
+		return "You forgot to request an item."
+	}
+	if b.currency.isMoney(normalizeName(item)) {
+		return "You can't sell coins silly!"
+	}
+
+	// Request count should always be greater than 0!
+	if count == 0 {
+		return "You can't sell 0 of an item, silly!"
+	} else if count < 0 {
+		return "You can't sell a negative number of items, silly!"
+	}
+
+	name := b.itemID(item)
+	itemFromSeller := record{
+		count: -count,
+		name:  name,
+		price: Unchanged,
+	}
+	itemToMerchant := record{
+		count: count,
+		name:  name,
+		price: Unchanged,
+	}
+
+	var response bytes.Buffer
+	tx := b.begin("sell", seller, merchant)
+	defer tx.release()
+
+	// Find what the merchant pays.
+	recs, err := tx.loadRecords(merchant)
+	if err != nil {
+		log.Println(err)
+		return FatalMessage
+	}
+	var offer money
+	for _, r := range recs {
+		if r.name == name {
+			offer = r.offer
+		}
+	}
+	if offer <= 0 {
+		response.WriteString(fmt.Sprintf(
+			"%v does not buy %v\n",
+			merchant,
+			b.catalog.name(name, 2),
+		))
+		response.WriteString(offers(merchant, recs.buying(), b.view()))
+		return response.String()
+	}
+	// Fractions of a coin are rounded up as there is no smaller coin to pay
+	// them with.
+	total, ok := offer.times(count)
+	if !ok {
+		return OverflowMessage
+	}
+	sum, ok := total.ceil()
+	if !ok {
+		return OverflowMessage
+	}
+
+	// The settings stay locked until the sale is done so the budget can't
+	// be spent twice.
+	unlock := b.lockSettings()
+	defer unlock()
+	st, err := b.loadSettings()
+	if err != nil {
+		log.Printf("error loading settings: %v\n", err)
+		return FatalMessage
+	}
+	budget, hasBudget := st.Budgets[merchant]
+	if hasBudget && budget < sum {
+		return fmt.Sprintf(
+			"%v can't spend %v, it only has a budget of %v",
+			merchant,
+			b.currency.formatCoins(sum),
+			b.currency.formatCoins(budget),
+		)
+	}
+
+	// Remove item from seller.
+	sellerUpdated, _, err := tx.updateRecord(itemFromSeller, seller, false)
+	if _, ok := err.(*declinedError); ok {
+		response.WriteString(fmt.Sprintf(
+			"%v does not have %v to sell\n",
+			seller,
+			itemToMerchant.display(b.view()),
+		))
+		response.WriteString("Please choose one of the following items:\n")
+		response.WriteString(b.displayInvetory(seller, false))
+		return response.String()
+	} else if err != nil {
+		log.Println(err)
+		return FatalMessage
+	}
+
+	// Pay the seller.
+	err = tx.pay(b.currency, merchant, seller, sum)
+	if _, ok := err.(*declinedError); ok {
+		return fmt.Sprintf(
+			"%v has insufficient funds\n"+
+				"%v costs %v\n"+
+				"%v only has %v",
+			merchant,
+			itemToMerchant.display(b.view()), b.currency.formatCoins(sum),
+			merchant, b.currency.balance(recs),
+		)
+	} else if _, ok := err.(*overflowError); ok {
+		return OverflowMessage
+	} else if err != nil {
+		log.Println(err)
+		return FatalMessage
+	}
+
+	// Give item to merchant.
+	merchantUpdated, _, err := tx.updateRecord(itemToMerchant, merchant, false)
+	if _, ok := err.(*overflowError); ok {
+		return OverflowMessage
+	} else if err != nil {
+		log.Printf("error in sell request %v %v: "+
+			"failed to give %v to merchant: %v\n", count, item, itemToMerchant, err)
+		return FatalMessage
+	}
+
+	// The budget is spent before the coins move so a failure never lets
+	// the merchant spend past it.
+	if hasBudget {
+		st.Budgets[merchant] -= sum
+		if err := b.storeSettings(st); err != nil {
+			log.Printf("error in sell request %v %v: "+
+				"failed to reduce budget of %v: %v\n", count, item, merchant, err)
+			return FatalMessage
+		}
+	}
+	if err := tx.commit(); err != nil {
+		log.Printf("error in sell request %v %v: %v\n", count, item, err)
+		if hasBudget {
+			st.Budgets[merchant] += sum
+			if err := b.storeSettings(st); err != nil {
+				log.Printf("error in sell request %v %v: "+
+					"failed to restore budget of %v: %v\n", count, item, merchant, err)
+			}
+		}
+		return FatalMessage
+	}
+
+	response.WriteString(fmt.Sprintf(
+		"%v sold %v for %v\n",
+		seller,
+		itemToMerchant.display(b.view()),
+		b.currency.formatCoins(sum),
+	))
+	response.WriteString(fmt.Sprintf("%v has %v\n", seller, sellerUpdated.display(b.view())))
+	response.WriteString(fmt.Sprintf(
+		"%v has %v",
+		merchant,
+		merchantUpdated.display(b.view()),
+	))
+	return response.String()
+}
+
+// offers lists the items a merchant buys back and what it pays.
+func offers(merchant string, recs records, v view) string {
+	if len(recs) == 0 {
+		return merchant + " does not buy anything."
+	}
+	var items []string
+	for _, r := range recs {
+		items = append(items, fmt.Sprintf(
+			"%v (%v)",
+			v.catalog.name(r.name, 2),
+			v.currency.format(r.offer),
+		))
+	}
+	return merchant + " buys: " + strings.Join(items, ", ")
+}
+
+// setOffer sets the price owner pays to buy an item back. An offer of 0 stops
+// buying the item back.
+func (b backpack) setOffer(item string, offer money, owner string) string {
+	log.Println(owner, "offer", item, offer)
+	if item == "" {
+		return "You forgot to request an item."
+	}
+	if offer < 0 {
+		return "Invalid offer. Please use a positive number."
+	}
+
+	tx := b.begin("offer", owner)
+	defer tx.release()
+	updated, err := tx.setOffer(b.itemID(item), offer, owner)
+	if err == nil {
+		err = tx.commit()
+	}
+	if err != nil {
+		log.Println(err)
+		return FatalMessage
+	}
+	if offer == 0 {
+		return fmt.Sprintf(
+			"%v no longer buys %v",
+			owner,
+			b.catalog.name(updated.name, 2),
+		)
+	}
+	return fmt.Sprintf(
+		"%v buys %v for %v",
+		owner,
+		b.catalog.name(updated.name, 2),
+		b.currency.format(offer),
+	)
+}
+
+// setBudget limits how many coins owner spends buying items back. A negative
+// budget removes the limit.
+func (b backpack) setBudget(budget int, owner string) string {
+	log.Println(owner, "budget", budget)
+	err := b.updateSettings(func(st *settings) error {
+		if budget < 0 {
+			delete(st.Budgets, owner)
+			return nil
+		}
+		if st.Budgets == nil {
+			st.Budgets = make(map[string]int)
+		}
+		st.Budgets[owner] = budget
+		return nil
+	})
+	if err != nil {
+		log.Printf("error setting budget of %v: %v\n", owner, err)
+		return FatalMessage
+	}
+	if budget < 0 {
+		return fmt.Sprintf("%v no longer has a budget", owner)
+	}
+	return fmt.Sprintf(
+		"%v has a budget of %v",
+		owner,
+		b.currency.formatCoins(budget),
+	)
+}
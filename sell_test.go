@@ -0,0 +1,243 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSellItem(t *testing.T) {
+	type test struct {
+		count    int
+		item     string
+		seller   string
+		merchant string
+		budget   int
+
+		wantReply    string
+		sellerWant   string
+		merchantWant string
+		budgetWant   int
+	}
+
+	tests := []test{
+		{
+			count:    2,
+			item:     "longswords",
+			seller:   "2,longsword,-1",
+			merchant: "1,longsword,15,8\n100,coin,-1",
+			budget:   -1,
+			wantReply: "seller sold 2 Longswords for $16\n" +
+				"seller has 0 Longswords\n" +
+				"merchant has 3 Longswords for sale for $15 bought for $8",
+			sellerWant:   "0,longsword,-1\n16,coin,-1",
+			merchantWant: "3,longsword,15,8\n84,coin,-1",
+			budgetWant:   -1,
+		},
+		{
+			count:    2,
+			item:     "longswords",
+			seller:   "2,longsword,-1",
+			merchant: "0,longsword,-1,8\n100,coin,-1",
+			budget:   20,
+			wantReply: "seller sold 2 Longswords for $16\n" +
+				"seller has 0 Longswords\n" +
+				"merchant has 2 Longswords bought for $8",
+			sellerWant:   "0,longsword,-1\n16,coin,-1",
+			merchantWant: "2,longsword,-1,8\n84,coin,-1",
+			budgetWant:   4,
+		},
+		{
+			count:        2,
+			item:         "longswords",
+			seller:       "2,longsword,-1",
+			merchant:     "0,longsword,-1,8\n100,coin,-1",
+			budget:       10,
+			wantReply:    "merchant can't spend $16, it only has a budget of $10",
+			sellerWant:   "2,longsword,-1",
+			merchantWant: "0,longsword,-1,8\n100,coin,-1",
+			budgetWant:   10,
+		},
+		{
+			count:    1,
+			item:     "apple",
+			seller:   "2,apple,-1",
+			merchant: "0,longsword,-1,8\n1,arrow,1,1\n100,coin,-1",
+			budget:   -1,
+			wantReply: "merchant does not buy Apples\n" +
+				"merchant buys: Longswords ($8), Arrows ($1)",
+			sellerWant:   "2,apple,-1",
+			merchantWant: "0,longsword,-1,8\n1,arrow,1,1\n100,coin,-1",
+			budgetWant:   -1,
+		},
+		{
+			count:    3,
+			item:     "longsword",
+			seller:   "2,longsword,-1",
+			merchant: "0,longsword,-1,8\n100,coin,-1",
+			budget:   -1,
+			wantReply: "seller does not have 3 Longswords to sell\n" +
+				"Please choose one of the following items:\n" +
+				"```\n" +
+				"╔══════════════════════╗\n" +
+				"║ Quantity  Item       ║\n" +
+				"║──────────────────────║\n" +
+				"║ 2         Longswords ║\n" +
+				"╚══════════════════════╝\n" +
+				"```",
+			sellerWant:   "2,longsword,-1",
+			merchantWant: "0,longsword,-1,8\n100,coin,-1",
+			budgetWant:   -1,
+		},
+		{
+			count:    2,
+			item:     "longsword",
+			seller:   "2,longsword,-1",
+			merchant: "0,longsword,-1,8\n10,coin,-1",
+			budget:   -1,
+			wantReply: "merchant has insufficient funds\n" +
+				"2 Longswords costs $16\n" +
+				"merchant only has 10 Coins",
+			sellerWant:   "2,longsword,-1",
+			merchantWant: "0,longsword,-1,8\n10,coin,-1",
+			budgetWant:   -1,
+		},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		sellerPath := filepath.Join(dir, "seller.csv")
+		err := os.WriteFile(sellerPath, []byte(tc.seller), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		merchantPath := filepath.Join(dir, "merchant.csv")
+		err = os.WriteFile(merchantPath, []byte(tc.merchant), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := backpack{
+			dir: dir,
+		}
+		b.setBudget(tc.budget, "merchant")
+		reply := b.sellItem(tc.count, tc.item, "seller", "merchant")
+		if tc.wantReply != reply {
+			t.Fatalf(
+				"incorrect reply:\nwant:\n%v\ngot:\n%v\n",
+				tc.wantReply,
+				reply,
+			)
+		}
+
+		sellerGot, err := os.ReadFile(sellerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(sellerGot)) != tc.sellerWant {
+			t.Fatalf(
+				"incorrect seller inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.sellerWant,
+				string(sellerGot),
+			)
+		}
+		merchantGot, err := os.ReadFile(merchantPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(merchantGot)) != tc.merchantWant {
+			t.Fatalf(
+				"incorrect merchant inventory:\nwant:\n%v\ngot:\n%v\n",
+				tc.merchantWant,
+				string(merchantGot),
+			)
+		}
+
+		st, err := b.loadSettings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		budget, ok := st.Budgets["merchant"]
+		if !ok {
+			budget = -1
+		}
+		if budget != tc.budgetWant {
+			t.Fatalf("want budget: %v got: %v\n", tc.budgetWant, budget)
+		}
+	}
+}
+
+func TestSetOffer(t *testing.T) {
+	dir := t.TempDir()
+	b := backpack{dir: dir}
+	path := filepath.Join(dir, "shop.csv")
+	err := os.WriteFile(path, []byte("1,arrow,2"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := b.setOffer("arrows", 100, "shop"); got != "shop buys Arrows for $1" {
+		t.Fatalf("incorrect reply: %v", got)
+	}
+	b.setOffer("shields", 500, "shop")
+	b.setOffer("shield", 0, "shop")
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1,arrow,2,1\n0,shield,-1"
+	if strings.TrimSpace(string(got)) != want {
+		t.Fatalf("want:\n%v\ngot:\n%v\n", want, string(got))
+	}
+}
+
+func TestSellItemBudgetConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.csv":        "1,longsword,-1",
+		"b.csv":        "1,longsword,-1",
+		"merchant.csv": "100,coin,-1\n0,longsword,-1,8",
+	}
+	for name, data := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := backpack{dir: dir}
+	b.setBudget(10, "merchant")
+
+	// The budget only covers one of the sales.
+	var wg sync.WaitGroup
+	replies := make([]string, 2)
+	for i, seller := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(i int, seller string) {
+			defer wg.Done()
+			replies[i] = b.sellItem(1, "longsword", seller, "merchant")
+		}(i, seller)
+	}
+	wg.Wait()
+
+	var sold int
+	for _, reply := range replies {
+		if strings.Contains(reply, " sold ") {
+			sold++
+		}
+	}
+	if sold != 1 {
+		t.Fatalf("want 1 sale got %v:\n%v", sold, strings.Join(replies, "\n"))
+	}
+	st, err := b.loadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Budgets["merchant"] != 2 {
+		t.Fatalf("want budget 2 got %v", st.Budgets["merchant"])
+	}
+}
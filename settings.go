@@ -0,0 +1,106 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// settingsName is the name of the file holding a guild's settings.
+const settingsName = "settings.json"
+
+// settings configures backpack for a single guild. They are kept as JSON in the
+// guild's data directory.
+type settings struct {
+	// Owners are the named owners which may be used in addition to
+	// channels, users, and roles.
+	Owners []string `json:"owners,omitempty"`
+
+	// Gamemasters are the IDs of roles which may change any inventory.
+	Gamemasters []string `json:"gamemasters,omitempty"`
+
+	// Players are the IDs of roles which may view inventories and spend from
+	// their own. If empty, everyone is a player.
+	Players []string `json:"players,omitempty"`
+
+	// Budgets limit how many coins an owner spends buying items back. Owners
+	// without a budget spend until they run out of coins.
+	Budgets map[string]int `json:"budgets,omitempty"`
+
+	// TradeMinutes is how long trade offers stay open. Zero uses
+	// defaultTradeMinutes.
+	TradeMinutes int `json:"trade_minutes,omitempty"`
+
+	// Capacities limit how much an owner can carry. Owners without a
+	// capacity carry any amount.
+	Capacities map[string]float64 `json:"capacities,omitempty"`
+
+	// Encumbrance is what happens when an owner carries more than their
+	// capacity: encumbranceWarn or encumbranceDecline. Empty warns.
+	Encumbrance string `json:"encumbrance,omitempty"`
+
+	// Sorts are the orders owners' inventories are shown in when a view
+	// doesn't choose one, such as sortPrice. Other inventories are shown in
+	// the order items were added.
+	Sorts map[string]string `json:"sorts,omitempty"`
+
+	// Render is how inventories and receipts are shown: renderText or
+	// renderEmbed. Empty uses renderText.
+	Render string `json:"render,omitempty"`
+
+	// Currency is the guild's coins. If empty, a single coin is used.
+	Currency currency `json:"currency,omitempty"`
+}
+
+// loadSettings reads the guild's settings. Missing settings are left at their
+// defaults.
+func (b backpack) loadSettings() (settings, error) {
+	var st settings
+	path := filepath.Join(b.dir, settingsName)
+	d, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	} else if err != nil {
+		return st, fmt.Errorf("failed reading %v: %v", path, err)
+	}
+	if err := json.Unmarshal(d, &st); err != nil {
+		return st, fmt.Errorf("failed parsing %v: %v", path, err)
+	}
+	return st, nil
+}
+
+// lockSettings locks the guild's settings and returns a function which
+// unlocks them. Settings must only be stored while they are locked.
+func (b backpack) lockSettings() func() {
+	return inventoryLocks.lock(dataKey(b.storage(), settingsName))
+}
+
+// storeSettings writes the guild's settings.
+func (b backpack) storeSettings(st settings) error {
+	d, err := json.MarshalIndent(st, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(b.dir, settingsName), append(d, '\n'), 0600)
+}
+
+// updateSettings loads the guild's settings, passes them to update, and
+// stores the result. The settings are locked while update runs. If update
+// returns an error nothing is stored.
+func (b backpack) updateSettings(update func(*settings) error) error {
+	unlock := b.lockSettings()
+	defer unlock()
+
+	st, err := b.loadSettings()
+	if err != nil {
+		return err
+	}
+	if err := update(&st); err != nil {
+		return err
+	}
+	return b.storeSettings(st)
+}
@@ -0,0 +1,131 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Orders which inventories may be sorted in.
+const (
+	sortName     = "name"
+	sortQuantity = "quantity"
+	sortPrice    = "price"
+	sortValue    = "value"
+	sortRecent   = "recent"
+)
+
+// sorted returns a copy of the records in the given order. Names are sorted
+// alphabetically and everything else from most to least, so items without a
+// price or which never changed come last. Records which are equal keep their
+// order. An unknown order leaves the records as they are.
+//
+// changed holds when each item last changed and is only used when sorting by
+// sortRecent.
+func (rs records) sorted(by string, c catalog, changed map[string]time.Time) records {
+	recs := append(records(nil), rs...)
+	var less func(a, b record) bool
+	switch by {
+	case sortName:
+		less = func(a, b record) bool {
+			return strings.ToLower(c.name(a.name, 1)) <
+				strings.ToLower(c.name(b.name, 1))
+		}
+	case sortQuantity:
+		less = func(a, b record) bool {
+			return a.count > b.count
+		}
+	case sortPrice:
+		less = func(a, b record) bool {
+			return a.price > b.price
+		}
+	case sortValue:
+		less = func(a, b record) bool {
+			return a.value() > b.value()
+		}
+	case sortRecent:
+		less = func(a, b record) bool {
+			return changed[a.name].After(changed[b.name])
+		}
+	default:
+		return recs
+	}
+	sort.SliceStable(recs, func(i, j int) bool {
+		return less(recs[i], recs[j])
+	})
+	return recs
+}
+
+// value returns the worth of every item in the record at its price. Items not
+// for sale are worth nothing and values too large to count are the largest
+// amount of money.
+func (r record) value() money {
+	if r.price < 0 {
+		return 0
+	}
+	v, ok := r.price.times(r.count)
+	if !ok {
+		return math.MaxInt64
+	}
+	return v
+}
+
+// value returns the total worth of the records' items for sale, capped at the
+// largest amount of money.
+func (recs records) value() money {
+	var sum money
+	for _, r := range recs {
+		v := r.value()
+		if sum > math.MaxInt64-v {
+			return math.MaxInt64
+		}
+		sum += v
+	}
+	return sum
+}
+
+// changed returns when each of owner's items last changed according to the
+// ledger.
+func (b backpack) changed(owner string) (map[string]time.Time, error) {
+	entries, err := b.storage().loadLedger()
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]time.Time)
+	for _, e := range entries {
+		if e.owner == owner && e.time.After(changed[e.item]) {
+			changed[e.item] = e.time
+		}
+	}
+	return changed, nil
+}
+
+// setSort changes the order owner's inventory is shown in when a view doesn't
+// choose one.
+func (b backpack) setSort(by, owner string) string {
+	log.Println(owner, "sort", by)
+	err := b.updateSettings(func(st *settings) error {
+		if by == "" {
+			delete(st.Sorts, owner)
+			return nil
+		}
+		if st.Sorts == nil {
+			st.Sorts = make(map[string]string)
+		}
+		st.Sorts[owner] = by
+		return nil
+	})
+	if err != nil {
+		log.Printf("error setting sort of %v: %v\n", owner, err)
+		return FatalMessage
+	}
+	if by == "" {
+		return fmt.Sprintf("%v is shown in the order items were added", owner)
+	}
+	return fmt.Sprintf("%v is sorted by %v", owner, by)
+}
@@ -0,0 +1,88 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordsSorted(t *testing.T) {
+	recs := records{
+		{count: 2, name: "rope", price: NotForSale},
+		{count: 1, name: "longsword", price: 1500},
+		{count: 10, name: "arrow", price: 100},
+		{count: 3, name: "healing potion", price: 5000},
+	}
+	c := catalog{"healing potion": {Name: "Potion of healing"}}
+	day := func(d int) time.Time {
+		return time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	changed := map[string]time.Time{
+		"rope":           day(3),
+		"arrow":          day(1),
+		"healing potion": day(2),
+	}
+
+	tests := map[string][]string{
+		sortName:     {"arrow", "longsword", "healing potion", "rope"},
+		sortQuantity: {"arrow", "healing potion", "rope", "longsword"},
+		sortPrice:    {"healing potion", "longsword", "arrow", "rope"},
+		sortValue:    {"healing potion", "longsword", "arrow", "rope"},
+		sortRecent:   {"rope", "healing potion", "arrow", "longsword"},
+		"":           {"rope", "longsword", "arrow", "healing potion"},
+	}
+	for by, want := range tests {
+		var got []string
+		for _, r := range recs.sorted(by, c, changed) {
+			got = append(got, r.name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("sort by %q: want %v got %v", by, want, got)
+		}
+	}
+	if recs[0].name != "rope" {
+		t.Fatalf("sorting changed the original records: %v", recs)
+	}
+}
+
+func TestDefaultSort(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(
+		filepath.Join(dir, "shop.csv"),
+		[]byte("1,longsword,1500\n10,arrow,100"),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backpack{dir: dir}
+
+	before := func(reply, first, second string) bool {
+		i := strings.Index(reply, first)
+		return i >= 0 && i < strings.Index(reply, second)
+	}
+	if reply := b.inventoryPages("shop", "", "", "")[0]; !before(reply, "Longsword", "Arrows") {
+		t.Fatalf("unsorted view changed order:\n%v", reply)
+	}
+
+	want := "shop is sorted by quantity"
+	if reply := b.setSort(sortQuantity, "shop"); reply != want {
+		t.Fatalf("want %q got %q", want, reply)
+	}
+	if reply := b.inventoryPages("shop", "", "", "")[0]; !before(reply, "Arrows", "Longsword") {
+		t.Fatalf("default sort not used:\n%v", reply)
+	}
+	if reply := b.inventoryPages("shop", "", "", sortPrice)[0]; !before(reply, "Longsword", "Arrows") {
+		t.Fatalf("chosen sort not used:\n%v", reply)
+	}
+
+	b.setSort("", "shop")
+	if reply := b.inventoryPages("shop", "", "", "")[0]; !before(reply, "Longsword", "Arrows") {
+		t.Fatalf("default sort not removed:\n%v", reply)
+	}
+}
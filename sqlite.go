@@ -0,0 +1,483 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteName is the name of the database file in the data directory.
+const sqliteName = "backpack.db"
+
+// sqliteMigrations builds the database schema. Each migration is run once, in
+// order, and the number which have run is kept as the database's user_version.
+// Append new migrations instead of changing old ones.
+var sqliteMigrations = []string{`
+CREATE TABLE IF NOT EXISTS records (
+	owner    TEXT    NOT NULL,
+	position INTEGER NOT NULL,
+	count    INTEGER NOT NULL,
+	name     TEXT    NOT NULL,
+	price    INTEGER NOT NULL,
+	PRIMARY KEY (owner, position)
+);
+CREATE TABLE IF NOT EXISTS descriptions (
+	item        TEXT PRIMARY KEY,
+	description TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS ledger (
+	id          INTEGER PRIMARY KEY,
+	time        TEXT    NOT NULL,
+	interaction TEXT    NOT NULL,
+	actor       TEXT    NOT NULL,
+	op          TEXT    NOT NULL,
+	owner       TEXT    NOT NULL,
+	item        TEXT    NOT NULL,
+	delta       INTEGER NOT NULL,
+	price       INTEGER NOT NULL
+);
+`, `
+ALTER TABLE ledger ADD COLUMN old_price INTEGER NOT NULL DEFAULT -1;
+ALTER TABLE ledger ADD COLUMN ref TEXT NOT NULL DEFAULT '';
+UPDATE ledger SET old_price = price;
+`, `
+ALTER TABLE records ADD COLUMN offer INTEGER NOT NULL DEFAULT 0;
+`, `
+-- Prices are kept in hundredths of a coin. Negative prices are markers such as
+-- NotForSale and are left alone.
+UPDATE records SET price = price * 100 WHERE price >= 0;
+UPDATE records SET offer = offer * 100;
+UPDATE ledger SET price = price * 100 WHERE price >= 0;
+UPDATE ledger SET old_price = old_price * 100 WHERE old_price >= 0;
+`, `
+CREATE TABLE IF NOT EXISTS items (
+	id   TEXT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS aliases (
+	alias TEXT PRIMARY KEY,
+	id    TEXT NOT NULL
+);
+`, `
+ALTER TABLE items ADD COLUMN weight REAL NOT NULL DEFAULT 0;
+`, `
+ALTER TABLE items ADD COLUMN category TEXT NOT NULL DEFAULT '';
+ALTER TABLE items ADD COLUMN rarity TEXT NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS tags (
+	id  TEXT NOT NULL,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (id, tag)
+);
+`,
+}
+
+// sqliteStorage keeps inventories and descriptions in an SQLite database.
+// Only the rows of a record which changed are written.
+type sqliteStorage struct {
+	path string
+}
+
+// sqliteDBs holds every open database so each file is only opened once.
+var sqliteDBs = struct {
+	sync.Mutex
+	m map[string]*sql.DB
+}{m: make(map[string]*sql.DB)}
+
+// db returns the open database, creating it if needed.
+func (s sqliteStorage) db() (*sql.DB, error) {
+	sqliteDBs.Lock()
+	defer sqliteDBs.Unlock()
+	if db, ok := sqliteDBs.m[s.path]; ok {
+		return db, nil
+	}
+
+	db, err := sql.Open("sqlite", s.path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed opening %v: %v", s.path, err)
+	}
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed migrating %v: %v", s.path, err)
+	}
+	sqliteDBs.m[s.path] = db
+	return db, nil
+}
+
+// migrateSQLite runs every migration which has not yet been run on db.
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// PRAGMA does not accept parameters.
+		_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s sqliteStorage) String() string {
+	return s.path
+}
+
+// loadRecords returns the inventory of owner in the order it was added.
+func (s sqliteStorage) loadRecords(owner string) (records, error) {
+	var recs records
+	db, err := s.db()
+	if err != nil {
+		return recs, err
+	}
+	rows, err := db.Query(
+		"SELECT count, name, price, offer FROM records WHERE owner = ? ORDER BY position",
+		owner,
+	)
+	if err != nil {
+		return recs, fmt.Errorf("failed loading %v: %v", owner, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r record
+		if err := rows.Scan(&r.count, &r.name, &r.price, &r.offer); err != nil {
+			return recs, fmt.Errorf("failed loading %v: %v", owner, err)
+		}
+		recs = append(recs, r)
+	}
+	return recs, rows.Err()
+}
+
+// storeRecords writes the inventories in changes in a single transaction.
+func (s sqliteStorage) storeRecords(changes map[string]records, entries []ledgerEntry) error {
+	db, err := s.db()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for owner, recs := range changes {
+		for i, r := range recs {
+			_, err := tx.Exec(`
+				INSERT INTO records (owner, position, count, name, price, offer)
+				VALUES (?, ?, ?, ?, ?, ?)
+				ON CONFLICT (owner, position) DO UPDATE SET
+					count = excluded.count,
+					name = excluded.name,
+					price = excluded.price,
+					offer = excluded.offer
+				WHERE count != excluded.count
+					OR name != excluded.name
+					OR price != excluded.price
+					OR offer != excluded.offer`,
+				owner, i, r.count, r.name, r.price, r.offer,
+			)
+			if err != nil {
+				return fmt.Errorf("failed storing %v: %v", owner, err)
+			}
+		}
+		_, err := tx.Exec(
+			"DELETE FROM records WHERE owner = ? AND position >= ?",
+			owner, len(recs),
+		)
+		if err != nil {
+			return fmt.Errorf("failed storing %v: %v", owner, err)
+		}
+	}
+	if err := insertLedger(tx, entries); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// appendLedger inserts entries into the ledger in a single transaction.
+func (s sqliteStorage) appendLedger(entries []ledgerEntry) error {
+	db, err := s.db()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertLedger(tx, entries); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertLedger inserts entries into the ledger as part of tx.
+func insertLedger(tx *sql.Tx, entries []ledgerEntry) error {
+	for _, e := range entries {
+		_, err := tx.Exec(`
+			INSERT INTO ledger (
+				time, interaction, actor, op, owner, item,
+				delta, price, old_price, ref
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.time.Format(time.RFC3339),
+			e.interaction,
+			e.actor,
+			e.op,
+			e.owner,
+			e.item,
+			e.delta,
+			e.price,
+			e.oldPrice,
+			e.ref,
+		)
+		if err != nil {
+			return fmt.Errorf("failed appending to ledger: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadLedger returns every ledger entry in the order they were added.
+func (s sqliteStorage) loadLedger() ([]ledgerEntry, error) {
+	db, err := s.db()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`
+		SELECT
+			time, interaction, actor, op, owner, item,
+			delta, price, old_price, ref
+		FROM ledger ORDER BY id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading ledger: %v", err)
+	}
+	defer rows.Close()
+	var entries []ledgerEntry
+	for rows.Next() {
+		fields := make([]string, 10)
+		ptrs := make([]any, len(fields))
+		for i := range fields {
+			ptrs[i] = &fields[i]
+		}
+		// Prices are stored in hundredths rather than as written in the
+		// ledger file.
+		var price, oldPrice money
+		ptrs[7], ptrs[8] = &price, &oldPrice
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed loading ledger: %v", err)
+		}
+		fields[7], fields[8] = formatPrice(price), formatPrice(oldPrice)
+		e, err := parseLedgerEntry(fields)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// owners lists every owner with at least one record.
+func (s sqliteStorage) owners() ([]string, error) {
+	db, err := s.db()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT DISTINCT owner FROM records ORDER BY owner")
+	if err != nil {
+		return nil, fmt.Errorf("failed listing owners: %v", err)
+	}
+	defer rows.Close()
+	var owners []string
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, fmt.Errorf("failed listing owners: %v", err)
+		}
+		owners = append(owners, owner)
+	}
+	return owners, rows.Err()
+}
+
+// loadDescriptions returns a mapping of items to descriptions.
+func (s sqliteStorage) loadDescriptions() (map[string]string, error) {
+	db, err := s.db()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT item, description FROM descriptions")
+	if err != nil {
+		return nil, fmt.Errorf("failed loading descriptions: %v", err)
+	}
+	defer rows.Close()
+	descriptions := make(map[string]string)
+	for rows.Next() {
+		var item, description string
+		if err := rows.Scan(&item, &description); err != nil {
+			return nil, fmt.Errorf("failed loading descriptions: %v", err)
+		}
+		descriptions[item] = description
+	}
+	return descriptions, rows.Err()
+}
+
+// storeDescriptions replaces all descriptions in a single transaction.
+func (s sqliteStorage) storeDescriptions(descriptions map[string]string) error {
+	db, err := s.db()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM descriptions"); err != nil {
+		return fmt.Errorf("failed storing descriptions: %v", err)
+	}
+	for item, description := range descriptions {
+		_, err := tx.Exec(
+			"INSERT INTO descriptions (item, description) VALUES (?, ?)",
+			item, description,
+		)
+		if err != nil {
+			return fmt.Errorf("failed storing descriptions: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// loadCatalog returns the names, aliases, and labels of items.
+func (s sqliteStorage) loadCatalog() (catalog, error) {
+	db, err := s.db()
+	if err != nil {
+		return nil, err
+	}
+	c := make(catalog)
+	rows, err := db.Query(
+		"SELECT id, name, weight, category, rarity FROM items",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading catalog: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name, category, rarity string
+		var weight float64
+		err := rows.Scan(&id, &name, &weight, &category, &rarity)
+		if err != nil {
+			return nil, fmt.Errorf("failed loading catalog: %v", err)
+		}
+		item := c[id]
+		item.Name = name
+		item.Weight = weight
+		item.Category = category
+		item.Rarity = rarity
+		c[id] = item
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT alias, id FROM aliases ORDER BY alias")
+	if err != nil {
+		return nil, fmt.Errorf("failed loading catalog: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias, id string
+		if err := rows.Scan(&alias, &id); err != nil {
+			return nil, fmt.Errorf("failed loading catalog: %v", err)
+		}
+		item := c[id]
+		item.Aliases = append(item.Aliases, alias)
+		c[id] = item
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT id, tag FROM tags ORDER BY tag")
+	if err != nil {
+		return nil, fmt.Errorf("failed loading catalog: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, fmt.Errorf("failed loading catalog: %v", err)
+		}
+		item := c[id]
+		item.Tags = append(item.Tags, tag)
+		c[id] = item
+	}
+	return c, rows.Err()
+}
+
+// storeCatalog replaces the whole catalog in a single transaction.
+func (s sqliteStorage) storeCatalog(c catalog) error {
+	db, err := s.db()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"items", "aliases", "tags"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("failed storing catalog: %v", err)
+		}
+	}
+	for id, item := range c {
+		_, err := tx.Exec(
+			"INSERT INTO items (id, name, weight, category, rarity) "+
+				"VALUES (?, ?, ?, ?, ?)",
+			id, item.Name, item.Weight, item.Category, item.Rarity,
+		)
+		if err != nil {
+			return fmt.Errorf("failed storing catalog: %v", err)
+		}
+		for _, alias := range item.Aliases {
+			_, err := tx.Exec(
+				"INSERT INTO aliases (alias, id) VALUES (?, ?)",
+				alias, id,
+			)
+			if err != nil {
+				return fmt.Errorf("failed storing catalog: %v", err)
+			}
+		}
+		for _, tag := range item.Tags {
+			_, err := tx.Exec(
+				"INSERT INTO tags (id, tag) VALUES (?, ?)",
+				id, tag,
+			)
+			if err != nil {
+				return fmt.Errorf("failed storing catalog: %v", err)
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,646 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// storage persists inventories, the item catalog, and item descriptions.
+type storage interface {
+	// loadRecords returns the inventory of owner. An owner without an
+	// inventory has no records.
+	loadRecords(owner string) (records, error)
+
+	// storeRecords replaces the inventories of every owner in changes and
+	// appends entries to the ledger. Either all or none of the inventories
+	// and entries are stored.
+	storeRecords(changes map[string]records, entries []ledgerEntry) error
+
+	// loadDescriptions returns a mapping of items to descriptions.
+	loadDescriptions() (map[string]string, error)
+
+	// storeDescriptions replaces all item descriptions.
+	storeDescriptions(descriptions map[string]string) error
+
+	// loadCatalog returns the names and aliases of items. Descriptions are
+	// not included.
+	loadCatalog() (catalog, error)
+
+	// storeCatalog replaces the names and aliases of every item.
+	storeCatalog(c catalog) error
+
+	// appendLedger adds entries to the end of the ledger.
+	appendLedger(entries []ledgerEntry) error
+
+	// loadLedger returns every ledger entry, oldest first.
+	loadLedger() ([]ledgerEntry, error)
+
+	// owners lists every owner with an inventory.
+	owners() ([]string, error)
+
+	// String describes where the data is kept. It is used in logs and to
+	// tell storages apart when locking inventories.
+	String() string
+}
+
+// Storage backends which may be selected with BACKPACK_STORAGE.
+const (
+	csvBackend    = "csv"
+	sqliteBackend = "sqlite"
+)
+
+// storage returns the storage backend for the data directory.
+func (b backpack) storage() storage {
+	if b.backend == sqliteBackend {
+		return sqliteStorage{path: filepath.Join(b.dir, sqliteName)}
+	}
+	return csvStorage{dir: b.dir}
+}
+
+// journalPattern matches the files listing the renames of transactions which
+// are being committed. Owners may not contain dots so it never matches an
+// inventory.
+const journalPattern = "journal.*.csv"
+
+// tmpSuffix is appended to the path of an inventory while it is staged.
+const tmpSuffix = ".tmp"
+
+// descriptionsName is the name of the file holding item descriptions.
+const descriptionsName = "descriptions.json"
+
+// legacyDescriptionsName is the name of the key value file which held item
+// descriptions before they were kept as JSON. It is read until the
+// descriptions are next stored, which removes it.
+const legacyDescriptionsName = "descriptions.kv"
+
+// ledgerName is the name of the csv file holding the ledger.
+const ledgerName = "ledger.log"
+
+// catalogName is the name of the file holding item names and aliases.
+const catalogName = "catalog.json"
+
+// csvStorage keeps each inventory in a csv file named after its owner and the
+// descriptions in a JSON file, all in a single directory.
+type csvStorage struct {
+	dir string
+}
+
+func (s csvStorage) String() string {
+	return s.dir
+}
+
+// fileName returns the name of the csv file holding owner's inventory. Owners
+// which could escape the directory are rejected.
+func (s csvStorage) fileName(owner string) (string, error) {
+	if owner == "" ||
+		owner == "." ||
+		owner == ".." ||
+		strings.ContainsAny(owner, `/\`+"\x00") {
+		return "", fmt.Errorf("invalid owner: %q", owner)
+	}
+	return owner + ".csv", nil
+}
+
+// loadRecords reads the inventory of owner from dir/owner.csv.
+func (s csvStorage) loadRecords(owner string) (records, error) {
+	name, err := s.fileName(owner)
+	if err != nil {
+		return nil, err
+	}
+	return loadRecords(filepath.Join(s.dir, name))
+}
+
+// storeRecords writes the inventories in changes and appends entries to the
+// ledger all at once.
+//
+// Each inventory, and the entries, are first written to a temporary file. A
+// journal listing the renames, and where in the ledger the entries go, is then
+// written and the temporary files are renamed over the inventories and the
+// entries appended. If the process dies part way through, replayJournal
+// finishes them on the next start so either all or none of the changes are
+// visible.
+func (s csvStorage) storeRecords(changes map[string]records, entries []ledgerEntry) error {
+	if len(changes) == 0 && len(entries) == 0 {
+		return nil
+	}
+	owners := make([]string, 0, len(changes))
+	for owner := range changes {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	var journal [][]string
+	for _, owner := range owners {
+		name, err := s.fileName(owner)
+		if err != nil {
+			return err
+		}
+		err = storeRecords(
+			filepath.Join(s.dir, name+tmpSuffix),
+			changes[owner],
+		)
+		if err != nil {
+			return err
+		}
+		journal = append(journal, []string{name + tmpSuffix, name})
+	}
+
+	if len(entries) > 0 {
+		// The ledger must not grow between finding where the entries go
+		// and appending them.
+		ledgerPath := filepath.Join(s.dir, ledgerName)
+		unlock := inventoryLocks.lock(dataKey(s, "ledger"))
+		defer unlock()
+
+		staged, err := s.stageLedger(entries)
+		if err != nil {
+			return err
+		}
+		var offset int64
+		info, err := os.Stat(ledgerPath)
+		if err == nil {
+			offset = info.Size()
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed reading %v: %v", ledgerPath, err)
+		}
+		journal = append(journal, []string{
+			staged,
+			ledgerName,
+			strconv.FormatInt(offset, 10),
+		})
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.WriteAll(journal)
+	w.Flush()
+	f, err := os.CreateTemp(s.dir, journalPattern+tmpSuffix)
+	if err != nil {
+		return fmt.Errorf("failed creating journal: %v", err)
+	}
+	tmpPath := f.Name()
+	f.Close()
+	if err := writeFileSync(tmpPath, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	journalPath := strings.TrimSuffix(tmpPath, tmpSuffix)
+	if err := os.Rename(tmpPath, journalPath); err != nil {
+		return fmt.Errorf("failed writing journal: %v", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	return applyJournal(journalPath)
+}
+
+// stageLedger writes entries to a temporary file to be appended to the ledger
+// and returns its name.
+func (s csvStorage) stageLedger(entries []ledgerEntry) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for _, e := range entries {
+		w.Write(e.fields())
+	}
+	w.Flush()
+
+	f, err := os.CreateTemp(s.dir, ledgerName+".*"+tmpSuffix)
+	if err != nil {
+		return "", fmt.Errorf("failed staging ledger: %v", err)
+	}
+	path := f.Name()
+	f.Close()
+	if err := writeFileSync(path, buf.Bytes(), 0600); err != nil {
+		return "", err
+	}
+	return filepath.Base(path), nil
+}
+
+// replayJournal finishes any transactions which were interrupted while being
+// committed in dir and removes the temporary files left by transactions which
+// never reached their journal. It must only be called while no transactions
+// are running.
+func replayJournal(dir string) error {
+	journals, err := filepath.Glob(filepath.Join(dir, journalPattern))
+	if err != nil {
+		return err
+	}
+	for _, journal := range journals {
+		if err := applyJournal(journal); err != nil {
+			return err
+		}
+	}
+
+	// Anything still staged was never journaled so it must be thrown away.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed reading %v: %v", dir, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), tmpSuffix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return fmt.Errorf("failed removing %v: %v", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// applyJournal performs the renames and ledger appends listed in the journal
+// at path and then removes it. Renames which were already done are skipped.
+// Appends first cut the ledger back to where the entries go, so appending
+// again after being interrupted doesn't repeat them.
+func applyJournal(path string) error {
+	dir := filepath.Dir(path)
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed reading %v: %v", path, err)
+	}
+	r := csv.NewReader(bytes.NewReader(d))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed parsing %v: %v", path, err)
+	}
+	var staged []string
+	for _, row := range rows {
+		from := filepath.Join(dir, filepath.Base(row[0]))
+		switch len(row) {
+		case 2:
+			to := filepath.Join(dir, filepath.Base(row[1]))
+			err := os.Rename(from, to)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed replaying journal: %v", err)
+			}
+		case 3:
+			to := filepath.Join(dir, filepath.Base(row[1]))
+			offset, err := strconv.ParseInt(row[2], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid journal entry: %v", row)
+			}
+			if err := appendAt(from, to, offset); err != nil {
+				return fmt.Errorf("failed replaying journal: %v", err)
+			}
+			staged = append(staged, from)
+		default:
+			return fmt.Errorf("invalid journal entry: %v", row)
+		}
+	}
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	for _, name := range staged {
+		err := os.Remove(name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed removing %v: %v", name, err)
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed removing journal: %v", err)
+	}
+	return nil
+}
+
+// appendAt writes the contents of the file from into the file to at offset,
+// dropping anything after it. Nothing is done if from no longer exists as it
+// was already appended.
+func appendAt(from, to string, offset int64) error {
+	d, err := os.ReadFile(from)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if info.Size() < offset {
+		f.Close()
+		return fmt.Errorf("%v is shorter than %v", to, offset)
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteAt(d, offset); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// owners lists the owners of every csv file in the directory.
+func (s csvStorage) owners() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed reading %v: %v", s.dir, err)
+	}
+	var owners []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".csv") {
+			continue
+		}
+		if ok, _ := filepath.Match(journalPattern, name); ok {
+			continue
+		}
+		owners = append(owners, strings.TrimSuffix(name, ".csv"))
+	}
+	return owners, nil
+}
+
+// appendLedger appends entries to the ledger file.
+func (s csvStorage) appendLedger(entries []ledgerEntry) error {
+	return s.storeRecords(nil, entries)
+}
+
+// loadLedger reads the ledger file.
+func (s csvStorage) loadLedger() ([]ledgerEntry, error) {
+	path := filepath.Join(s.dir, ledgerName)
+	d, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed reading %v: %v", path, err)
+	}
+	lines, err := csv.NewReader(bytes.NewReader(d)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing %v: %v", path, err)
+	}
+	entries := make([]ledgerEntry, 0, len(lines))
+	for _, line := range lines {
+		e, err := parseLedgerEntry(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// loadCatalog reads the catalog file.
+func (s csvStorage) loadCatalog() (catalog, error) {
+	c := make(catalog)
+	path := filepath.Join(s.dir, catalogName)
+	d, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed reading %v: %v", path, err)
+	}
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, fmt.Errorf("failed parsing %v: %v", path, err)
+	}
+	return c, nil
+}
+
+// storeCatalog writes the catalog file.
+func (s csvStorage) storeCatalog(c catalog) error {
+	d, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(
+		filepath.Join(s.dir, catalogName),
+		append(d, '\n'),
+		0600,
+	)
+}
+
+// loadDescriptions reads the descriptions file. If it doesn't exist yet the
+// legacy key value file is read instead.
+func (s csvStorage) loadDescriptions() (map[string]string, error) {
+	descriptions := make(map[string]string)
+	path := filepath.Join(s.dir, descriptionsName)
+	d, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s.loadLegacyDescriptions()
+	} else if err != nil {
+		return nil, fmt.Errorf("failed reading %v: %v", path, err)
+	}
+	if err := json.Unmarshal(d, &descriptions); err != nil {
+		return nil, fmt.Errorf("failed parsing %v: %v", path, err)
+	}
+	return descriptions, nil
+}
+
+// loadLegacyDescriptions reads the key value file descriptions used to be kept
+// in, with an item and its description on each line separated by "=". Only
+// the first "=" separates them. A line without one was written by a
+// description containing a newline, so it continues the previous description.
+func (s csvStorage) loadLegacyDescriptions() (map[string]string, error) {
+	descriptions := make(map[string]string)
+	file, err := os.Open(filepath.Join(s.dir, legacyDescriptionsName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return descriptions, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var item string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		a := strings.SplitN(line, "=", 2)
+		if len(a) == 2 {
+			item = a[0]
+			descriptions[item] = a[1]
+			continue
+		}
+		if item == "" {
+			return nil, fmt.Errorf("invalid description: %v", line)
+		}
+		descriptions[item] += "\n" + line
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading descriptions: %v", err)
+	}
+	return descriptions, nil
+}
+
+// storeDescriptions writes the descriptions file and removes the legacy key
+// value file, whose descriptions are now included. Items are written in sorted
+// order so the file only changes where a description did.
+func (s csvStorage) storeDescriptions(descriptions map[string]string) error {
+	// Maps are always encoded with their keys sorted.
+	d, err := json.MarshalIndent(descriptions, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(
+		filepath.Join(s.dir, descriptionsName),
+		append(d, '\n'),
+		0600,
+	)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(s.dir, legacyDescriptionsName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// loadRecords reads a csv file located at path and parses the contents into a
+// list of records.
+func loadRecords(path string) (records, error) {
+	var recs records
+
+	d, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return recs, fmt.Errorf("failed reading %v: %v", path, err)
+	}
+	r := csv.NewReader(bytes.NewReader(d))
+	// Records which are bought back have an extra offer field.
+	r.FieldsPerRecord = -1
+
+	for {
+		line, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return recs, fmt.Errorf("failed parsing %v: %v", path, err)
+		}
+		if len(line) != 3 && len(line) != 4 {
+			return recs, fmt.Errorf("failed parsing %v: %v", path, line)
+		}
+		count, err := strconv.Atoi(line[0])
+		if err != nil {
+			return recs, fmt.Errorf(
+				"failed parsing count as int: %v",
+				line[0],
+			)
+		}
+		price, err := parsePrice(line[2])
+		if err != nil {
+			return recs, fmt.Errorf(
+				"failed parsing price: %v",
+				line[2],
+			)
+		}
+		rec := record{
+			count: count,
+			name:  line[1],
+			price: price,
+		}
+		if len(line) == 4 {
+			rec.offer, err = parseMoney(line[3])
+			if err != nil {
+				return recs, fmt.Errorf(
+					"failed parsing offer: %v",
+					line[3],
+				)
+			}
+		}
+		recs = append(recs, rec)
+	}
+
+	return recs, nil
+}
+
+// storeRecords writes a list of records to a csv file at path.
+func storeRecords(path string, records records) error {
+	var lines [][]string
+	for _, r := range records {
+		count := strconv.Itoa(r.count)
+		price := formatPrice(r.price)
+		line := []string{
+			count,
+			r.name,
+			price,
+		}
+		if r.offer > 0 {
+			line = append(line, r.offer.String())
+		}
+		lines = append(lines, line)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	w.WriteAll(lines)
+	w.Flush()
+	return writeFileSync(path, buf.Bytes(), 0600)
+}
+
+// writeFileSync works like os.WriteFile, but the data is flushed to disk
+// before returning.
+func writeFileSync(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeFileAtomic works like writeFileSync, but the data is written to a
+// temporary file which is then renamed over path so readers never see a
+// partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*"+tmpSuffix)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+	if err := writeFileSync(tmp, data, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes the entries of the directory at path to disk so renames
+// within it are durable.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed syncing %v: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStorage(t *testing.T) {
+	for _, backend := range []string{csvBackend, sqliteBackend} {
+		b := backpack{
+			dir:     t.TempDir(),
+			backend: backend,
+		}
+		s := b.storage()
+
+		recs, err := s.loadRecords("owner")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recs) != 0 {
+			t.Fatalf("%v: missing inventory has records: %v", backend, recs)
+		}
+
+		changes := map[string]records{
+			"a": {
+				{count: 1, name: "apple", price: 500},
+				{count: 10, name: "arrow", price: NotForSale},
+			},
+			"b": {
+				{count: 20, name: "coin", price: NotForSale},
+			},
+		}
+		if err := s.storeRecords(changes, nil); err != nil {
+			t.Fatal(err)
+		}
+		// Shrink an inventory to check that old records are dropped.
+		changes = map[string]records{
+			"a": {
+				{count: 0, name: "apple", price: 500},
+			},
+		}
+		if err := s.storeRecords(changes, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]records{
+			"a": {{count: 0, name: "apple", price: 500}},
+			"b": {{count: 20, name: "coin", price: NotForSale}},
+		}
+		for owner, w := range want {
+			got, err := s.loadRecords(owner)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, w) {
+				t.Fatalf("%v: %v want: %v got: %v\n", backend, owner, w, got)
+			}
+		}
+
+		owners, err := s.owners()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(owners, []string{"a", "b"}) {
+			t.Fatalf("%v: want owners [a b] got: %v\n", backend, owners)
+		}
+
+		descriptions := map[string]string{
+			"apple":  "A red fruit.",
+			"potion": "Heals 2d4+2.\nDC=15 to brew.\n\n",
+			"a=b":    "",
+		}
+		if err := s.storeDescriptions(descriptions); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.loadDescriptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, descriptions) {
+			t.Fatalf("%v: want: %v got: %v\n", backend, descriptions, got)
+		}
+
+		c := catalog{
+			"healing potion": {
+				Name:    "Potion of healing",
+				Aliases: []string{"hp potion", "red potion"},
+			},
+			"arrow": {
+				Aliases:  []string{"bolt"},
+				Weight:   0.05,
+				Category: "ammunition",
+				Rarity:   "common",
+				Tags:     []string{"piercing", "ranged"},
+			},
+		}
+		if err := s.storeCatalog(c); err != nil {
+			t.Fatal(err)
+		}
+		gotCatalog, err := s.loadCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotCatalog, c) {
+			t.Fatalf("%v: want: %v got: %v\n", backend, c, gotCatalog)
+		}
+
+		entries := []ledgerEntry{
+			{
+				time:        time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+				interaction: "1",
+				actor:       "2",
+				op:          "buy",
+				owner:       "a",
+				item:        "apple",
+				delta:       -1,
+				price:       500,
+			},
+			{
+				time:  time.Date(2022, 1, 2, 3, 4, 6, 0, time.UTC),
+				op:    "add",
+				owner: "b, \"c\"",
+				item:  "coin",
+				delta: 20,
+				price: NotForSale,
+			},
+		}
+		err = s.appendLedger(entries[:1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Entries stored with inventories are appended along with them.
+		changes = map[string]records{
+			"b": {{count: 40, name: "coin", price: NotForSale}},
+		}
+		if err := s.storeRecords(changes, entries[1:]); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := s.loadRecords("b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(stored, changes["b"]) {
+			t.Fatalf("%v: want: %v got: %v\n", backend, changes["b"], stored)
+		}
+		ledger, err := s.loadLedger()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ledger, entries) {
+			t.Fatalf("%v: want: %v got: %v\n", backend, entries, ledger)
+		}
+	}
+}
+
+func TestLegacyDescriptions(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, legacyDescriptionsName)
+	err := os.WriteFile(
+		legacy,
+		[]byte("apple=A red fruit.\npotion=Heals 2d4+2, DC=15 to brew.\nrope=\n"),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := csvStorage{dir: dir}
+
+	want := map[string]string{
+		"apple":  "A red fruit.",
+		"potion": "Heals 2d4+2, DC=15 to brew.",
+		"rope":   "",
+	}
+	got, err := s.loadDescriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %q got: %q", want, got)
+	}
+
+	// A description with a newline used to be written across several lines.
+	err = os.WriteFile(legacy, []byte("apple=A red fruit.\nIt is crisp.\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.loadDescriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{"apple": "A red fruit.\nIt is crisp."}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %q got: %q", want, got)
+	}
+
+	// Storing moves the descriptions to the new file.
+	want["pear"] = "A green fruit."
+	if err := s.storeDescriptions(want); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Fatalf("legacy descriptions not removed: %v", err)
+	}
+	got, err = s.loadDescriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %q got: %q", want, got)
+	}
+
+	err = os.WriteFile(legacy, []byte("No item here.\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(dir, descriptionsName))
+	if _, err := s.loadDescriptions(); err == nil {
+		t.Fatal("invalid legacy descriptions loaded")
+	}
+}
+
+func TestStoreDescriptionsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, descriptionsName)
+	// Older versions wrote descriptions readable by everyone.
+	if err := os.WriteFile(path, []byte("{}"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	s := csvStorage{dir: dir}
+
+	descriptions := map[string]string{
+		"rope":   "Fifty feet.",
+		"apple":  "A red fruit.",
+		"potion": "Heals 2d4+2.",
+	}
+	if err := s.storeDescriptions(descriptions); err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n" +
+		"\t\"apple\": \"A red fruit.\",\n" +
+		"\t\"potion\": \"Heals 2d4+2.\",\n" +
+		"\t\"rope\": \"Fifty feet.\"\n" +
+		"}\n"
+	if string(first) != want {
+		t.Fatalf("want:\n%v\ngot:\n%v", want, string(first))
+	}
+	for i := 0; i < 10; i++ {
+		if err := s.storeDescriptions(descriptions); err != nil {
+			t.Fatal(err)
+		}
+		again, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(first) {
+			t.Fatalf("descriptions written in a different order:\n%v", string(again))
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("want permissions 0600 got %v", perm)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,210 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Ways a view may be grouped into sections.
+const (
+	groupCategory = "category"
+	groupRarity   = "rarity"
+)
+
+// untagged names the section of items without a category or rarity.
+const untagged = "other"
+
+// clearLabel removes an item's category or rarity.
+const clearLabel = "none"
+
+// rarities are the usual item rarities from least to most rare. Sections for
+// them are shown in this order, followed by any other rarities.
+var rarities = []string{
+	"common",
+	"uncommon",
+	"rare",
+	"very rare",
+	"legendary",
+	"artifact",
+}
+
+// normalizeLabel writes a category, rarity, or tag the way it is stored so
+// "Consumables" and "consumable" are the same.
+func normalizeLabel(label string) string {
+	return strings.ToLower(normalizeName(label))
+}
+
+// labeled reports whether the item with id has the category, rarity, or tag.
+func (c catalog) labeled(id, label string) bool {
+	label = normalizeLabel(label)
+	item := c[id]
+	if item.Category == label || item.Rarity == label {
+		return true
+	}
+	return contains(item.Tags, label)
+}
+
+// labels returns every category, rarity, and tag used in the catalog.
+func (c catalog) labels() []string {
+	seen := make(map[string]bool)
+	var labels []string
+	add := func(label string) {
+		if label != "" && !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+	}
+	for _, item := range c {
+		add(item.Category)
+		add(item.Rarity)
+		for _, tag := range item.Tags {
+			add(tag)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// filter returns the records whose item has the category, rarity, or tag.
+func (rs records) filter(c catalog, label string) records {
+	var recs records
+	for _, r := range rs {
+		if c.labeled(r.name, label) {
+			recs = append(recs, r)
+		}
+	}
+	return recs
+}
+
+// section is a titled part of a grouped view.
+type section struct {
+	title string
+	recs  records
+}
+
+// group splits the records into sections by their item's category or
+// rarity. Categories are sorted by name and rarities from least to most rare.
+// Items without one are put in a last section.
+func (rs records) group(c catalog, by string) []section {
+	key := func(id string) string {
+		if by == groupRarity {
+			return c[id].Rarity
+		}
+		return c[id].Category
+	}
+
+	var titles []string
+	sections := make(map[string]records)
+	for _, r := range rs {
+		if r.count == 0 {
+			continue
+		}
+		k := key(r.name)
+		if _, ok := sections[k]; !ok {
+			titles = append(titles, k)
+		}
+		sections[k] = append(sections[k], r)
+	}
+
+	rank := func(title string) int {
+		if title == "" {
+			return len(rarities) + 1
+		}
+		if by != groupRarity {
+			return 0
+		}
+		for i, r := range rarities {
+			if r == title {
+				return i
+			}
+		}
+		return len(rarities)
+	}
+	sort.Slice(titles, func(i, j int) bool {
+		ri, rj := rank(titles[i]), rank(titles[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return titles[i] < titles[j]
+	})
+
+	var groups []section
+	for _, t := range titles {
+		title := t
+		if title == "" {
+			title = untagged
+		}
+		groups = append(groups, section{title: title, recs: sections[t]})
+	}
+	return groups
+}
+
+// grouped prints tables for each section of the records, with the section's
+// title above them. Sections are split into tables which each fit in limit
+// characters along with their title.
+func (rs records) grouped(v view, by string, limit int) []string {
+	var blocks []string
+	for _, s := range rs.group(v.catalog, by) {
+		title := "**" + displayName(s.title, 2) + "**\n"
+		for _, page := range s.recs.pages(v, limit-len(title)) {
+			blocks = append(blocks, title+page)
+		}
+	}
+	return blocks
+}
+
+// tagItem changes an item's category, rarity, and tags. Empty arguments are
+// left alone and a category or rarity of "none" removes it.
+func (b backpack) tagItem(item, category, rarity, tag, untag string) string {
+	log.Println("tag", item, category, rarity, tag, untag)
+	if item == "" {
+		return "You forgot to request an item."
+	}
+	id := b.itemID(item)
+	edited, err := b.updateCatalog(id, func(c catalog, it *catalogItem) error {
+		if category != "" {
+			it.Category = normalizeLabel(category)
+			if it.Category == clearLabel {
+				it.Category = ""
+			}
+		}
+		if rarity != "" {
+			it.Rarity = normalizeLabel(rarity)
+			if it.Rarity == clearLabel {
+				it.Rarity = ""
+			}
+		}
+		if tag != "" && !contains(it.Tags, normalizeLabel(tag)) {
+			it.Tags = append(it.Tags, normalizeLabel(tag))
+		}
+		if untag != "" {
+			it.Tags = remove(it.Tags, normalizeLabel(untag))
+		}
+		sort.Strings(it.Tags)
+		return nil
+	})
+	if err != nil {
+		log.Printf("error tagging catalog item %v: %v\n", id, err)
+		return FatalMessage
+	}
+
+	var parts []string
+	if edited.Rarity != "" {
+		parts = append(parts, edited.Rarity)
+	}
+	if edited.Category != "" {
+		parts = append(parts, edited.Category)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "uncategorized")
+	}
+	msg := b.catalog.name(id, 1) + ": " + strings.Join(parts, " ")
+	if len(edited.Tags) > 0 {
+		msg += fmt.Sprintf(", tagged %v", strings.Join(edited.Tags, ", "))
+	}
+	return msg
+}
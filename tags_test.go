@@ -0,0 +1,135 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testTagged = catalog{
+	"longsword":      {Category: "weapon", Rarity: "common", Tags: []string{"melee"}},
+	"flame tongue":   {Category: "weapon", Rarity: "rare", Tags: []string{"magic", "melee"}},
+	"healing potion": {Category: "consumable", Rarity: "common"},
+	"elixir":         {Category: "consumable", Rarity: "very rare", Tags: []string{"magic"}},
+}
+
+func TestRecordsFilter(t *testing.T) {
+	recs := records{
+		{count: 1, name: "longsword", price: NotForSale},
+		{count: 1, name: "flame tongue", price: NotForSale},
+		{count: 3, name: "healing potion", price: NotForSale},
+		{count: 1, name: "elixir", price: NotForSale},
+		{count: 10, name: "rope", price: NotForSale},
+	}
+	tests := map[string][]string{
+		"consumables": {"healing potion", "elixir"},
+		"Magic":       {"flame tongue", "elixir"},
+		"very rare":   {"elixir"},
+		"armor":       nil,
+	}
+	for label, want := range tests {
+		var got []string
+		for _, r := range recs.filter(testTagged, label) {
+			got = append(got, r.name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("filter %q: want %v got %v", label, want, got)
+		}
+	}
+}
+
+func TestRecordsGroup(t *testing.T) {
+	recs := records{
+		{count: 1, name: "elixir", price: NotForSale},
+		{count: 10, name: "rope", price: NotForSale},
+		{count: 1, name: "flame tongue", price: NotForSale},
+		{count: 3, name: "healing potion", price: NotForSale},
+		{count: 0, name: "longsword", price: NotForSale},
+	}
+	type want struct {
+		title string
+		names []string
+	}
+	tests := map[string][]want{
+		groupCategory: {
+			{"consumable", []string{"elixir", "healing potion"}},
+			{"weapon", []string{"flame tongue"}},
+			{untagged, []string{"rope"}},
+		},
+		groupRarity: {
+			{"common", []string{"healing potion"}},
+			{"rare", []string{"flame tongue"}},
+			{"very rare", []string{"elixir"}},
+			{untagged, []string{"rope"}},
+		},
+	}
+	for by, w := range tests {
+		var got []want
+		for _, s := range recs.group(testTagged, by) {
+			var names []string
+			for _, r := range s.recs {
+				names = append(names, r.name)
+			}
+			got = append(got, want{s.title, names})
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Fatalf("group by %v: want %v got %v", by, w, got)
+		}
+	}
+}
+
+func TestTagItem(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(
+		filepath.Join(dir, "shop.csv"),
+		[]byte("1,longsword,15\n3,healing potion,50"),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backpack{dir: dir}
+	reload := func() {
+		b.catalog, err = b.loadCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		item, category, rarity, tag, untag string
+		want                               string
+	}{
+		{"longswords", "Weapons", "", "", "", "Longsword: weapon"},
+		{"longsword", "", "Uncommon", "melee", "", "Longsword: uncommon weapon, tagged melee"},
+		{"longsword", "", "none", "", "melee", "Longsword: weapon"},
+		{"healing potion", "consumable", "", "", "", "Healing potion: consumable"},
+	}
+	for _, s := range steps {
+		got := b.tagItem(s.item, s.category, s.rarity, s.tag, s.untag)
+		if got != s.want {
+			t.Fatalf("want %q got %q", s.want, got)
+		}
+		reload()
+	}
+
+	reply := b.inventoryPages("shop", "consumables", "", "")[0]
+	if !strings.Contains(reply, "Healing potions") ||
+		strings.Contains(reply, "Longsword") {
+		t.Fatalf("incorrect filtered view:\n%v", reply)
+	}
+	reply = b.inventoryPages("shop", "armor", "", "")[0]
+	if reply != "shop has no armor items" {
+		t.Fatalf("incorrect empty view: %v", reply)
+	}
+	reply = b.inventoryPages("shop", "", groupCategory, "")[0]
+	consumables := strings.Index(reply, "**Consumables**")
+	weapons := strings.Index(reply, "**Weapons**")
+	if consumables < 0 || weapons < consumables {
+		t.Fatalf("incorrect grouped view:\n%v", reply)
+	}
+}
@@ -0,0 +1,311 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tradesName is the name of the file holding a guild's open trade offers.
+const tradesName = "trades.json"
+
+// defaultTradeMinutes is how long trade offers stay open unless configured.
+const defaultTradeMinutes = 60
+
+// tradeItem is a number of a single item in a trade.
+type tradeItem struct {
+	Count int    `json:"count"`
+	Name  string `json:"name"`
+}
+
+// trade is an offer to exchange items between two inventories. The inventory
+// receiving the offer accepts or declines it.
+type trade struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Give    []tradeItem `json:"give"`
+	Want    []tradeItem `json:"want"`
+	Expires time.Time   `json:"expires"`
+
+	// Proposer is the ID of the user who offered the trade.
+	Proposer string `json:"proposer"`
+}
+
+// String prints out a pretty message describing the trade.
+func (t trade) String() string {
+	return t.display(view{})
+}
+
+// display describes the trade like String, using the names of v.
+func (t trade) display(v view) string {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("%v offers %v a trade\n", t.From, t.To))
+	buf.WriteString(fmt.Sprintf("%v gives: %v\n", t.From, bundleString(t.Give, v)))
+	buf.WriteString(fmt.Sprintf("%v gives: %v\n", t.To, bundleString(t.Want, v)))
+	buf.WriteString(fmt.Sprintf("Expires <t:%v:R>", t.Expires.Unix()))
+	return buf.String()
+}
+
+// bundleString lists the items in a bundle.
+func bundleString(items []tradeItem, v view) string {
+	if len(items) == 0 {
+		return "nothing"
+	}
+	var names []string
+	for _, item := range items {
+		r := record{count: item.Count, name: item.Name, price: Unchanged}
+		names = append(names, r.display(v))
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseBundle reads a comma separated list of items such as
+// "2 arrows, 1 longsword, 50". Like add and remove, a count comes before the
+// item's name and a missing name means the least valuable coin of v's
+// currency. Names are resolved through v's catalog. An empty list is an empty
+// bundle.
+func parseBundle(s string, v view) ([]tradeItem, error) {
+	var items []tradeItem
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		count := 1
+		if n, err := strconv.Atoi(fields[0]); err == nil {
+			count = n
+			fields = fields[1:]
+		}
+		if count <= 0 {
+			return nil, fmt.Errorf("\"%v\" must have a count above 0", part)
+		}
+		name := v.currency.base()
+		if len(fields) > 0 {
+			name = v.catalog.resolve(normalizeName(strings.Join(fields, " ")))
+		}
+		items = append(items, tradeItem{Count: count, Name: name})
+	}
+	return items, nil
+}
+
+// loadTrades reads the guild's open trade offers.
+func (b backpack) loadTrades() (map[string]trade, error) {
+	trades := make(map[string]trade)
+	path := filepath.Join(b.dir, tradesName)
+	d, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return trades, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed reading %v: %v", path, err)
+	}
+	if err := json.Unmarshal(d, &trades); err != nil {
+		return nil, fmt.Errorf("failed parsing %v: %v", path, err)
+	}
+	return trades, nil
+}
+
+// updateTrades loads the guild's open trade offers, passes them to update,
+// and stores the result. Expired offers are dropped. The offers are locked
+// while update runs.
+func (b backpack) updateTrades(update func(map[string]trade) error) error {
+	path := filepath.Join(b.dir, tradesName)
+	unlock := inventoryLocks.lock(dataKey(b.storage(), tradesName))
+	defer unlock()
+
+	trades, err := b.loadTrades()
+	if err != nil {
+		return err
+	}
+	if err := update(trades); err != nil {
+		return err
+	}
+	now := time.Now()
+	for id, t := range trades {
+		if now.After(t.Expires) {
+			delete(trades, id)
+		}
+	}
+	d, err := json.MarshalIndent(trades, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(d, '\n'), 0600)
+}
+
+// proposeTrade opens a trade offer from one inventory to another. The offer
+// is stored under id, which should be unique, and the message to show with
+// accept and decline buttons is returned. ok is false if the offer is invalid
+// and the message explains why.
+func (b backpack) proposeTrade(id, from, to, give, want string) (string, bool) {
+	log.Println(from, "offered", to, "a trade of", give, "for", want)
+	if from == to {
+		return "You can't trade with yourself, silly!", false
+	}
+	t := trade{
+		From:     from,
+		To:       to,
+		Proposer: b.actor,
+	}
+	var err error
+	t.Give, err = parseBundle(give, b.view())
+	if err != nil {
+		return "Invalid trade: " + err.Error(), false
+	}
+	t.Want, err = parseBundle(want, b.view())
+	if err != nil {
+		return "Invalid trade: " + err.Error(), false
+	}
+	if len(t.Give) == 0 && len(t.Want) == 0 {
+		return "You forgot to request any items.", false
+	}
+
+	st, err := b.loadSettings()
+	if err != nil {
+		log.Printf("error loading settings: %v\n", err)
+		return FatalMessage, false
+	}
+	minutes := st.TradeMinutes
+	if minutes <= 0 {
+		minutes = defaultTradeMinutes
+	}
+	t.Expires = time.Now().Add(time.Duration(minutes) * time.Minute).UTC()
+
+	err = b.updateTrades(func(trades map[string]trade) error {
+		trades[id] = t
+		return nil
+	})
+	if err != nil {
+		log.Printf("error storing trade: %v\n", err)
+		return FatalMessage, false
+	}
+	return t.display(b.view()), true
+}
+
+// errNoTrade is returned when a trade offer does not exist or has expired.
+var errNoTrade = errors.New("trade offer not found")
+
+// closeTrade removes the trade offer stored under id and returns it.
+func (b backpack) closeTrade(id string) (trade, error) {
+	var t trade
+	err := b.updateTrades(func(trades map[string]trade) error {
+		var ok bool
+		t, ok = trades[id]
+		if !ok || time.Now().After(t.Expires) {
+			return errNoTrade
+		}
+		delete(trades, id)
+		return nil
+	})
+	return t, err
+}
+
+// loadTrade returns the open trade offer stored under id.
+func (b backpack) loadTrade(id string) (trade, error) {
+	trades, err := b.loadTrades()
+	if err != nil {
+		return trade{}, err
+	}
+	t, ok := trades[id]
+	if !ok || time.Now().After(t.Expires) {
+		return t, errNoTrade
+	}
+	return t, nil
+}
+
+// acceptTrade swaps the items of the trade offer stored under id. Either both
+// inventories change or neither does, and the offer is closed either way.
+func (b backpack) acceptTrade(id string) string {
+	t, err := b.closeTrade(id)
+	if err == errNoTrade {
+		return "This trade offer has expired."
+	} else if err != nil {
+		log.Printf("error closing trade %v: %v\n", id, err)
+		return FatalMessage
+	}
+	log.Println(t.To, "accepted a trade from", t.From)
+
+	tx := b.begin("trade", t.From, t.To)
+	defer tx.release()
+	for _, side := range []struct {
+		items    []tradeItem
+		from, to string
+	}{
+		{t.Give, t.From, t.To},
+		{t.Want, t.To, t.From},
+	} {
+		for _, item := range side.items {
+			r := record{
+				count: item.Count,
+				name:  item.Name,
+				price: Unchanged,
+			}
+			_, _, err := tx.updateRecord(
+				record{count: -r.count, name: r.name, price: r.price},
+				side.from,
+				false,
+			)
+			if _, ok := err.(*declinedError); ok {
+				return fmt.Sprintf(
+					"Trade declined, %v does not have %v\n%v",
+					side.from,
+					r.display(b.view()),
+					describeTrade(t, b.view()),
+				)
+			} else if err != nil {
+				log.Printf("error in trade %v: %v\n", id, err)
+				return FatalMessage
+			}
+			_, _, err = tx.updateRecord(r, side.to, false)
+			if _, ok := err.(*overflowError); ok {
+				return OverflowMessage
+			} else if err != nil {
+				log.Printf("error in trade %v: %v\n", id, err)
+				return FatalMessage
+			}
+		}
+	}
+	if err := tx.commit(); err != nil {
+		log.Printf("error in trade %v: %v\n", id, err)
+		return FatalMessage
+	}
+
+	return fmt.Sprintf(
+		"%v and %v traded\n%v received: %v\n%v received: %v",
+		t.From, t.To,
+		t.To, bundleString(t.Give, b.view()),
+		t.From, bundleString(t.Want, b.view()),
+	)
+}
+
+// declineTrade closes the trade offer stored under id without trading.
+func (b backpack) declineTrade(id string) string {
+	t, err := b.closeTrade(id)
+	if err == errNoTrade {
+		return "This trade offer has expired."
+	} else if err != nil {
+		log.Printf("error closing trade %v: %v\n", id, err)
+		return FatalMessage
+	}
+	log.Println(t.To, "declined a trade from", t.From)
+	return fmt.Sprintf("Trade declined\n%v", describeTrade(t, b.view()))
+}
+
+// describeTrade describes what was offered in a closed trade.
+func describeTrade(t trade, v view) string {
+	return fmt.Sprintf(
+		"%v offered %v to %v for %v",
+		t.From,
+		bundleString(t.Give, v),
+		t.To,
+		bundleString(t.Want, v),
+	)
+}
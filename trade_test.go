@@ -0,0 +1,158 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBundle(t *testing.T) {
+	type test struct {
+		s    string
+		want []tradeItem
+		ok   bool
+	}
+
+	tests := []test{
+		{s: "", ok: true},
+		{
+			s: "2 arrows, longsword, 50",
+			want: []tradeItem{
+				{Count: 2, Name: "arrow"},
+				{Count: 1, Name: "longsword"},
+				{Count: 50, Name: "coin"},
+			},
+			ok: true,
+		},
+		{s: "0 arrows"},
+		{s: "-2 arrows"},
+	}
+	for _, tc := range tests {
+		got, err := parseBundle(tc.s, view{})
+		if tc.ok != (err == nil) {
+			t.Fatalf("%q: unexpected error: %v", tc.s, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%q: want: %v got: %v", tc.s, tc.want, got)
+		}
+	}
+}
+
+func TestTrade(t *testing.T) {
+	type test struct {
+		give string
+		want string
+
+		wantReply string
+		fromWant  string
+		toWant    string
+	}
+
+	tests := []test{
+		{
+			give: "2 arrows",
+			want: "1 longsword, 5",
+			wantReply: "from and to traded\n" +
+				"to received: 2 Arrows\n" +
+				"from received: 1 Longsword, 5 Coins",
+			fromWant: "8,arrow,-1\n15,coin,-1\n1,longsword,-1",
+			toWant:   "0,longsword,-1\n5,coin,-1\n2,arrow,-1",
+		},
+		{
+			give: "20 arrows",
+			want: "longsword",
+			wantReply: "Trade declined, from does not have 20 Arrows\n" +
+				"from offered 20 Arrows to to for 1 Longsword",
+			fromWant: "10,arrow,-1\n10,coin,-1",
+			toWant:   "1,longsword,-1\n10,coin,-1",
+		},
+		{
+			give: "2 arrows",
+			want: "20",
+			wantReply: "Trade declined, to does not have 20 Coins\n" +
+				"from offered 2 Arrows to to for 20 Coins",
+			fromWant: "10,arrow,-1\n10,coin,-1",
+			toWant:   "1,longsword,-1\n10,coin,-1",
+		},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		fromPath := filepath.Join(dir, "from.csv")
+		err := os.WriteFile(fromPath, []byte("10,arrow,-1\n10,coin,-1"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toPath := filepath.Join(dir, "to.csv")
+		err = os.WriteFile(toPath, []byte("1,longsword,-1\n10,coin,-1"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := backpack{dir: dir, actor: "1"}
+		if _, ok := b.proposeTrade("id", "from", "to", tc.give, tc.want); !ok {
+			t.Fatal("trade not proposed")
+		}
+		reply := b.acceptTrade("id")
+		if reply != tc.wantReply {
+			t.Fatalf("incorrect reply:\nwant:\n%v\ngot:\n%v\n", tc.wantReply, reply)
+		}
+		for path, want := range map[string]string{
+			fromPath: tc.fromWant,
+			toPath:   tc.toWant,
+		} {
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(string(got)) != want {
+				t.Fatalf("%v want:\n%v\ngot:\n%v\n", path, want, string(got))
+			}
+		}
+
+		// An offer can only be answered once.
+		if got := b.acceptTrade("id"); got != "This trade offer has expired." {
+			t.Fatalf("trade accepted twice: %v", got)
+		}
+	}
+}
+
+func TestTradeExpires(t *testing.T) {
+	b := backpack{dir: t.TempDir()}
+	err := b.updateTrades(func(trades map[string]trade) error {
+		trades["old"] = trade{
+			From:    "from",
+			To:      "to",
+			Give:    []tradeItem{{Count: 1, Name: "arrow"}},
+			Expires: time.Now().Add(time.Hour),
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.declineTrade("old"); !strings.HasPrefix(got, "Trade declined") {
+		t.Fatalf("open trade not declined: %v", got)
+	}
+
+	err = b.updateTrades(func(trades map[string]trade) error {
+		trades["old"] = trade{
+			From:    "from",
+			To:      "to",
+			Give:    []tradeItem{{Count: 1, Name: "arrow"}},
+			Expires: time.Now().Add(-time.Minute),
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.acceptTrade("old"); got != "This trade offer has expired." {
+		t.Fatalf("expired trade accepted: %v", got)
+	}
+}
@@ -0,0 +1,151 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// undoable reports whether the changes of op may be undone.
+func undoable(op string) bool {
+	switch op {
+	case "add", "remove", "set", "buy", "give", "sell", "trade":
+		return true
+	}
+	return false
+}
+
+// canUndo reports whether the member may reverse changes, which needs the same
+// access as making them now. Gamemaster changes need a gamemaster and the rest
+// need the member to still be able to spend from one of the inventories which
+// paid, the way the buyer, seller, or giver was checked. The owners which
+// paid are also returned.
+func (a access) canUndo(changes []ledgerEntry) (bool, []string) {
+	switch changes[0].op {
+	case "add", "remove", "set":
+		return a.canEdit(), nil
+	}
+	var paid []string
+	for _, e := range changes {
+		if e.delta >= 0 || contains(paid, e.owner) {
+			continue
+		}
+		if a.canSpend(e.owner) {
+			return true, nil
+		}
+		paid = append(paid, e.owner)
+	}
+	return false, paid
+}
+
+// undo reverses the last count transactions made by the user running the
+// current command. Both sides of a buy are reversed together. If a later
+// transaction used what is being returned, or the user no longer has access to
+// make the transaction, the undo is refused and nothing changes.
+func (b backpack) undo(count int, a access) string {
+	log.Println(b.actor, "undo", count)
+	if count < 1 {
+		return "You must undo at least 1 transaction."
+	}
+
+	entries, err := b.storage().loadLedger()
+	if err != nil {
+		log.Printf("error loading ledger: %v\n", err)
+		return FatalMessage
+	}
+
+	// Find the user's most recent transactions which were not yet undone.
+	undone := make(map[string]bool)
+	for _, e := range entries {
+		if e.op == "undo" {
+			undone[e.ref] = true
+		}
+	}
+	var interactions []string
+	changes := make(map[string][]ledgerEntry)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.actor != b.actor || !undoable(e.op) || undone[e.interaction] {
+			continue
+		}
+		if _, ok := changes[e.interaction]; !ok {
+			if len(interactions) == count {
+				continue
+			}
+			interactions = append(interactions, e.interaction)
+		}
+		changes[e.interaction] = append(changes[e.interaction], e)
+	}
+	if len(interactions) == 0 {
+		return "You have nothing to undo."
+	}
+
+	for _, id := range interactions {
+		ok, paid := a.canUndo(changes[id])
+		if ok {
+			continue
+		}
+		op := changes[id][0].op
+		if len(paid) == 0 {
+			return DeniedMessage + " Only gamemasters can undo " + op + "."
+		}
+		return fmt.Sprintf(
+			"%v You can no longer spend from %v to undo %v.",
+			DeniedMessage,
+			strings.Join(paid, " or "),
+			op,
+		)
+	}
+
+	var owners []string
+	for _, id := range interactions {
+		for _, e := range changes[id] {
+			owners = append(owners, e.owner)
+		}
+	}
+	tx := b.begin("undo", owners...)
+	defer tx.release()
+
+	// Changes are reversed newest first so each one sees the inventory as
+	// it was right after it was made.
+	var response bytes.Buffer
+	for _, id := range interactions {
+		tx.ref = id
+		for _, e := range changes[id] {
+			rec := record{
+				count: -e.delta,
+				name:  e.item,
+				price: e.oldPrice,
+			}
+			_, _, err := tx.updateRecord(rec, e.owner, false)
+			if _, ok := err.(*declinedError); ok {
+				return fmt.Sprintf(
+					"Can't undo, %v no longer has %v\n%v",
+					e.owner,
+					record{count: e.delta, name: e.item, price: Unchanged}.display(b.view()),
+					e.display(b.view()),
+				)
+			} else if _, ok := err.(*overflowError); ok {
+				return OverflowMessage
+			} else if err != nil {
+				log.Printf("error undoing %v: %v\n", id, err)
+				return FatalMessage
+			}
+			response.WriteString("\n" + e.display(b.view()))
+		}
+	}
+	if err := tx.commit(); err != nil {
+		log.Printf("error undoing %v: %v\n", interactions, err)
+		return FatalMessage
+	}
+
+	noun := "transactions"
+	if len(interactions) == 1 {
+		noun = "transaction"
+	}
+	return fmt.Sprintf("Undid %v %v:", len(interactions), noun) +
+		response.String()
+}
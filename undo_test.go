@@ -0,0 +1,97 @@
+// License: AGPL-3.0-only
+// (c) 2022 Dakota Walsh <kota@nilsu.org>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	dir := t.TempDir()
+	gmAccess := access{level: gamemaster}
+	playerAccess := access{level: player, own: map[string]bool{"buyer": true}}
+	gm := backpack{dir: dir, actor: "gm"}
+	player := backpack{dir: dir, actor: "player"}
+
+	read := func(owner string) string {
+		d, err := os.ReadFile(filepath.Join(dir, owner+".csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(d))
+	}
+
+	gm.interaction = "1"
+	gm.modifyItem(50, Unchanged, Coin, "buyer", "add")
+	gm.interaction = "2"
+	gm.modifyItem(20, 300, "apples", "seller", "add")
+	player.interaction = "3"
+	player.buyItem(2, "apple", "buyer", "seller")
+	gm.interaction = "4"
+	gm.modifyItem(0, 500, "apples", "seller", "set")
+
+	// Undo the fat-fingered set.
+	gm.interaction = "5"
+	gm.undo(1, gmAccess)
+	if got := read("seller"); got != "18,apple,3\n6,coin,-1" {
+		t.Fatalf("set not undone: %v", got)
+	}
+
+	// Undoing the buy must reverse both sides.
+	player.interaction = "6"
+	player.undo(1, playerAccess)
+	if got := read("buyer"); got != "50,coin,-1\n0,apple,-1" {
+		t.Fatalf("buyer not undone: %v", got)
+	}
+	if got := read("seller"); got != "20,apple,3\n0,coin,-1" {
+		t.Fatalf("seller not undone: %v", got)
+	}
+	if got := player.undo(1, playerAccess); got != "You have nothing to undo." {
+		t.Fatalf("undid a transaction twice: %v", got)
+	}
+
+	// Another user's transactions are never undone.
+	gm.interaction = "7"
+	gm.modifyItem(45, Unchanged, Coin, "buyer", "remove")
+	player.interaction = "8"
+	got := player.undo(1, playerAccess)
+	if got != "You have nothing to undo." {
+		t.Fatalf("undid another user's transaction: %v", got)
+	}
+	gm.interaction = "9"
+	gm.undo(1, gmAccess)
+
+	// The seller sold some apples so adding them can no longer be undone.
+	player.interaction = "10"
+	player.buyItem(2, "apple", "buyer", "seller")
+	gm.interaction = "11"
+	got = gm.undo(2, gmAccess)
+	if !strings.HasPrefix(got, "Can't undo, seller no longer has 20 Apples") {
+		t.Fatalf("undo went negative: %v", got)
+	}
+	if got := read("buyer"); got != "44,coin,-1\n2,apple,-1" {
+		t.Fatalf("refused undo changed inventory: %v", got)
+	}
+
+	// Undoing needs the access the transaction needed.
+	player.interaction = "12"
+	player.buyItem(1, "apple", "buyer", "seller")
+	gm.interaction = "13"
+	gm.modifyItem(5, Unchanged, Coin, "seller", "add")
+	demoted := access{level: playerAccess.level}
+	got = gm.undo(1, demoted)
+	if got != DeniedMessage+" Only gamemasters can undo add." {
+		t.Fatalf("undid a gamemaster change without being one: %v", got)
+	}
+	got = player.undo(1, demoted)
+	want := DeniedMessage + " You can no longer spend from buyer or seller to undo buy."
+	if got != want {
+		t.Fatalf("undid a buy without access to the buyer: %v", got)
+	}
+	if got := read("buyer"); got != "41,coin,-1\n3,apple,-1" {
+		t.Fatalf("refused undo changed inventory: %v", got)
+	}
+}